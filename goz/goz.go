@@ -1,6 +1,8 @@
 package goz
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"runtime"
 	"strconv"
@@ -14,9 +16,24 @@ const (
 	defaultStackDeep = 32
 )
 
+// ErrLimited reports that the limiter is saturated.
+var ErrLimited = errors.New("goz: limiter is saturated")
+
+// closedChan is returned by Limiter.Done when no goroutine is running.
+var closedChan = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+// Limiter limits the number of concurrent goroutines, the zero value has no limit.
 type Limiter struct {
-	c            chan struct{}
-	w            sync.WaitGroup
+	mu sync.Mutex
+	// limit of concurrent goroutines, 0 means no limit
+	limit        int
+	running      int
+	waiters      []chan struct{}
+	idle         chan struct{}
 	panicHandler func(any)
 }
 
@@ -24,16 +41,7 @@ type Limiter struct {
 // if limit is 0, it defaults to 3.
 // if limit is less than 0, it means no limit.
 func NewLimiter(limit int) *Limiter {
-	if limit < 0 {
-		return &Limiter{}
-	}
-
-	if limit == 0 {
-		limit = defaultLimit
-	}
-	return &Limiter{
-		c: make(chan struct{}, limit),
-	}
+	return &Limiter{limit: normalizeLimit(limit)}
 }
 
 func (l *Limiter) SetPanicHandler(fn func(any)) *Limiter {
@@ -41,47 +49,190 @@ func (l *Limiter) SetPanicHandler(fn func(any)) *Limiter {
 	return l
 }
 
-func (l *Limiter) Go(fn func()) *Limiter {
-	if l.c == nil {
-		l.w.Add(1)
-		go Recover(fn, l.panicHandler, l.w.Done)
-		return l
+// SetLimit changes the limit of concurrent goroutines at runtime, with the same meaning as NewLimiter.
+// Waiting goroutines are started if the limit grows, running goroutines are not affected if it shrinks.
+func (l *Limiter) SetLimit(limit int) *Limiter {
+	l.mu.Lock()
+	l.limit = normalizeLimit(limit)
+	l.grant()
+	l.mu.Unlock()
+	return l
+}
+
+// Limit returns the limit of concurrent goroutines, less than 0 means no limit.
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limit == 0 {
+		return -1
 	}
+	return l.limit
+}
+
+// Running returns the number of running goroutines.
+func (l *Limiter) Running() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.running
+}
 
-	l.add()
-	go Recover(fn, l.panicHandler, l.done)
+// Waiting returns the number of Go and GoCtx calls waiting for the limit.
+func (l *Limiter) Waiting() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.waiters)
+}
+
+// Go runs fn in a goroutine, it blocks while the limit is reached.
+func (l *Limiter) Go(fn func()) *Limiter {
+	go Recover(fn, l.panicHandler, l.start())
 	return l
 }
 
+// TryGo runs fn in a goroutine if the limit is not reached, and reports whether fn is started.
+func (l *Limiter) TryGo(fn func()) bool {
+	if l.acquire(nil, false) != nil {
+		return false
+	}
+
+	go Recover(fn, l.panicHandler, l.release)
+	return true
+}
+
+// GoCtx runs fn in a goroutine like Go, but gives up with the context error once ctx is done while waiting.
+func (l *Limiter) GoCtx(ctx context.Context, fn func()) error {
+	if err := l.acquire(ctx.Done(), true); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+
+	go Recover(fn, l.panicHandler, l.release)
+	return nil
+}
+
+// Done returns a channel which is closed once no goroutine is running.
 func (l *Limiter) Done() <-chan struct{} {
-	quit := make(chan struct{})
-	go func(ch chan<- struct{}) {
-		l.w.Wait()
-		close(ch)
-	}(quit)
-	return quit
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.idle == nil {
+		return closedChan
+	}
+	return l.idle
 }
 
+// Wait waits for the running goroutines to exit, at most waitTime[0] if it is specified.
 func (l *Limiter) Wait(waitTime ...time.Duration) {
 	if len(waitTime) > 0 {
+		timer := time.NewTimer(waitTime[0])
+		defer timer.Stop()
+
 		select {
 		case <-l.Done():
-		case <-time.After(waitTime[0]):
+		case <-timer.C:
 		}
 		return
 	}
 
-	l.w.Wait()
+	<-l.Done()
 }
 
-func (l *Limiter) add() {
-	l.c <- struct{}{}
-	l.w.Add(1)
+// start blocks until a goroutine can be started under the limit, and returns the function to call once it exits.
+func (l *Limiter) start() func() {
+	_ = l.acquire(nil, true)
+	return l.release
 }
 
-func (l *Limiter) done() {
-	l.w.Done()
-	<-l.c
+// acquire takes a slot of the limit, waiting in FIFO order if block is true until a slot is granted
+// or cancel is closed.
+func (l *Limiter) acquire(cancel <-chan struct{}, block bool) error {
+	l.mu.Lock()
+	if len(l.waiters) == 0 && l.available() {
+		l.inc()
+		l.mu.Unlock()
+		return nil
+	}
+
+	if !block {
+		l.mu.Unlock()
+		return ErrLimited
+	}
+
+	ready := make(chan struct{})
+	l.waiters = append(l.waiters, ready)
+	l.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-cancel:
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	select {
+	case <-ready:
+		// granted while being canceled
+		return nil
+	default:
+	}
+
+	for i, w := range l.waiters {
+		if w == ready {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			break
+		}
+	}
+	return ErrLimited
+}
+
+func (l *Limiter) release() {
+	l.mu.Lock()
+	l.running--
+	l.grant()
+	if l.running == 0 && l.idle != nil {
+		close(l.idle)
+		l.idle = nil
+	}
+	l.mu.Unlock()
+}
+
+// grant hands the free slots to the waiters, l.mu must be held.
+func (l *Limiter) grant() {
+	for len(l.waiters) > 0 && l.available() {
+		ready := l.waiters[0]
+		l.waiters[0] = nil
+		l.waiters = l.waiters[1:]
+		l.inc()
+		close(ready)
+	}
+}
+
+// available reports whether a goroutine can be started under the limit, l.mu must be held.
+func (l *Limiter) available() bool {
+	return l.limit == 0 || l.running < l.limit
+}
+
+// inc counts a running goroutine, l.mu must be held.
+func (l *Limiter) inc() {
+	l.running++
+	if l.idle == nil {
+		l.idle = make(chan struct{})
+	}
+}
+
+// normalizeLimit converts the limit of NewLimiter to the limit field.
+func normalizeLimit(limit int) int {
+	if limit < 0 {
+		return 0
+	}
+	if limit == 0 {
+		return defaultLimit
+	}
+	return limit
 }
 
 func Recover(fn func(), panicFn func(any), cleanups ...func()) {
@@ -1,7 +1,10 @@
 package goz
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -93,6 +96,26 @@ func TestLimiter_NoLimit(t *testing.T) {
 	}
 }
 
+func TestLimiter_ZeroValue(t *testing.T) {
+	var l Limiter
+	release := make(chan struct{})
+	for i := 0; i < 5; i++ {
+		l.Go(func() { <-release })
+	}
+	if !l.TryGo(func() { <-release }) {
+		t.Error("expected TryGo of the zero value to start")
+	}
+	if err := l.GoCtx(context.Background(), func() { <-release }); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if l.Running() != 7 || l.Waiting() != 0 || l.Limit() != -1 {
+		t.Errorf("expected 7 running without limit, got %d %d %d", l.Running(), l.Waiting(), l.Limit())
+	}
+
+	close(release)
+	l.Wait()
+}
+
 func TestLimiter_ZeroLimit(t *testing.T) {
 	begin := time.Now()
 	NewLimiter(0).Go(func() {
@@ -145,6 +168,113 @@ func TestLimiter_Wait(t *testing.T) {
 	t.Logf("wait %d ms", since)
 }
 
+func TestLimiter_SetLimit(t *testing.T) {
+	release := make(chan struct{})
+	l := NewLimiter(1)
+	l.Go(func() { <-release })
+
+	started := make(chan struct{}, 3)
+	for i := 0; i < 3; i++ {
+		go l.Go(func() {
+			started <- struct{}{}
+			<-release
+		})
+	}
+
+	waitFor(t, func() bool { return l.Waiting() == 3 })
+	if l.Running() != 1 {
+		t.Fatalf("expected 1 running, got %d", l.Running())
+	}
+
+	l.SetLimit(3)
+	<-started
+	<-started
+	waitFor(t, func() bool { return l.Running() == 3 && l.Waiting() == 1 })
+
+	l.SetLimit(1)
+	if l.Limit() != 1 || l.TryGo(func() {}) {
+		t.Error("expected TryGo to fail after the limit shrinks")
+	}
+
+	close(release)
+	l.Wait()
+	if l.Running() != 0 || l.Waiting() != 0 {
+		t.Errorf("expected no running or waiting, got %d %d", l.Running(), l.Waiting())
+	}
+}
+
+func TestLimiter_TryGo(t *testing.T) {
+	release := make(chan struct{})
+	l := NewLimiter(1)
+	if !l.TryGo(func() { <-release }) {
+		t.Fatal("expected TryGo to start")
+	}
+	if l.TryGo(func() {}) {
+		t.Error("expected TryGo to fail while saturated")
+	}
+	close(release)
+	l.Wait()
+
+	if !NewLimiter(-1).TryGo(func() {}) {
+		t.Error("expected TryGo of no limit to start")
+	}
+}
+
+func TestLimiter_GoCtx(t *testing.T) {
+	release := make(chan struct{})
+	l := NewLimiter(1)
+	if err := l.GoCtx(context.Background(), func() { <-release }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	var ran int32
+	if err := l.GoCtx(ctx, func() { atomic.StoreInt32(&ran, 1) }); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if l.Waiting() != 0 {
+		t.Errorf("expected the canceled waiter to be removed, got %d", l.Waiting())
+	}
+
+	close(release)
+	l.Wait()
+	if atomic.LoadInt32(&ran) != 0 {
+		t.Error("expected the canceled fn not to run")
+	}
+}
+
+func TestLimiter_WaitNoLeak(t *testing.T) {
+	release := make(chan struct{})
+	l := NewLimiter(1).Go(func() { <-release })
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		l.Wait(time.Microsecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("expected no leaked goroutine, got %d before and %d after", before, after)
+	}
+
+	close(release)
+	select {
+	case <-l.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected Done to be closed")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
 func TestLogPanic(t *testing.T) {
 	f := LogPanic(logger{}, 10)
 
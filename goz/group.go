@@ -0,0 +1,106 @@
+package goz
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// PanicError is the error of a recovered panic, it carries the stack trace of the panic.
+type PanicError struct {
+	// Value the value passed to panic
+	Value any
+	// Stack the stack trace of the panic
+	Stack string
+}
+
+func newPanicError(p any) *PanicError {
+	var buf strings.Builder
+	buf.Grow(512)
+	stack(&buf, 5, defaultStackDeep)
+	return &PanicError{Value: p, Stack: buf.String()}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v  Traceback:%s", e.Value, e.Stack)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Group runs tasks returning errors in goroutines with the concurrency limit of a Limiter.
+// By default, the first error cancels the context shared by the tasks and is returned by Wait.
+// Panics of the tasks are recovered and returned as *PanicError.
+type Group struct {
+	l          *Limiter
+	ctx        context.Context
+	cancel     context.CancelFunc
+	collectAll bool
+
+	mu   sync.Mutex
+	errs []error
+}
+
+// NewGroup creates a new Group and the context shared by its tasks, which is derived from ctx.
+// limit is the limit of concurrent tasks like NewLimiter, 0 defaults to 3 and less than 0 means no limit.
+func NewGroup(ctx context.Context, limit int) (*Group, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Group{l: NewLimiter(limit), ctx: ctx, cancel: cancel}, ctx
+}
+
+// CollectAll makes the errors not cancel the shared context, and Wait returns all errors joined.
+func (g *Group) CollectAll() *Group {
+	g.collectAll = true
+	return g
+}
+
+// Go runs fn with the shared context in a goroutine, it blocks while the limit of concurrent tasks is reached.
+func (g *Group) Go(fn func(ctx context.Context) error) *Group {
+	done := g.l.start()
+	go func() {
+		defer done()
+
+		if err := g.run(fn); err != nil {
+			g.mu.Lock()
+			g.errs = append(g.errs, err)
+			g.mu.Unlock()
+
+			if !g.collectAll {
+				g.cancel()
+			}
+		}
+	}()
+	return g
+}
+
+// Wait waits for all tasks to exit and cancels the shared context, it returns the first error,
+// or all errors joined if CollectAll is set.
+func (g *Group) Wait() error {
+	g.l.Wait()
+	g.cancel()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if len(g.errs) == 0 {
+		return nil
+	}
+	if g.collectAll {
+		return joinErrors(g.errs...)
+	}
+	return g.errs[0]
+}
+
+func (g *Group) run(fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = newPanicError(p)
+		}
+	}()
+
+	return fn(g.ctx)
+}
@@ -0,0 +1,119 @@
+package goz
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroup(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		var running, maxRunning, n int32
+		g, _ := NewGroup(context.Background(), 2)
+		for i := 0; i < 6; i++ {
+			g.Go(func(ctx context.Context) error {
+				cur := atomic.AddInt32(&running, 1)
+				for {
+					max := atomic.LoadInt32(&maxRunning)
+					if cur <= max || atomic.CompareAndSwapInt32(&maxRunning, max, cur) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				atomic.AddInt32(&n, 1)
+				return nil
+			})
+		}
+
+		if err := g.Wait(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if n != 6 || maxRunning > 2 {
+			t.Errorf("expected 6 tasks with at most 2 running, got %d tasks, %d running", n, maxRunning)
+		}
+	})
+
+	t.Run("first error cancels", func(t *testing.T) {
+		errFirst := errors.New("first")
+		g, ctx := NewGroup(context.Background(), -1)
+		g.Go(func(ctx context.Context) error {
+			return errFirst
+		}).Go(func(ctx context.Context) error {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+				return errors.New("not canceled")
+			}
+		})
+
+		if err := g.Wait(); err != errFirst {
+			t.Errorf("expected the first error, got %v", err)
+		}
+		if ctx.Err() == nil {
+			t.Error("expected the shared context to be canceled")
+		}
+	})
+
+	t.Run("collect all", func(t *testing.T) {
+		err1, err2 := errors.New("e1"), errors.New("e2")
+		var canceled int32
+		g, _ := NewGroup(context.Background(), 0)
+		g.CollectAll().Go(func(ctx context.Context) error {
+			return err1
+		}).Go(func(ctx context.Context) error {
+			time.Sleep(20 * time.Millisecond)
+			if ctx.Err() != nil {
+				atomic.StoreInt32(&canceled, 1)
+			}
+			return err2
+		}).Go(func(ctx context.Context) error {
+			return nil
+		})
+
+		err := g.Wait()
+		if !errors.Is(err, err1) || !errors.Is(err, err2) {
+			t.Errorf("expected all errors joined, got %v", err)
+		}
+		if canceled != 0 {
+			t.Error("expected the shared context not to be canceled by errors")
+		}
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		errCause := errors.New("cause")
+		g, _ := NewGroup(context.Background(), 1)
+		g.Go(func(ctx context.Context) error {
+			panic(errCause)
+		})
+
+		err := g.Wait()
+		var pe *PanicError
+		if !errors.As(err, &pe) {
+			t.Fatalf("expected *PanicError, got %v", err)
+		}
+		if !errors.Is(err, errCause) {
+			t.Errorf("expected the panic value to be unwrapped, got %v", err)
+		}
+		if !strings.Contains(pe.Stack, "group_test.go") {
+			t.Errorf("expected the stack of the panic, got %s", pe.Stack)
+		}
+	})
+
+	t.Run("parent canceled", func(t *testing.T) {
+		parent, cancel := context.WithCancel(context.Background())
+		cancel()
+		g, _ := NewGroup(parent, 1)
+		g.Go(func(ctx context.Context) error {
+			return ctx.Err()
+		})
+
+		if err := g.Wait(); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	})
+}
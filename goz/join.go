@@ -0,0 +1,9 @@
+//go:build go1.20
+
+package goz
+
+import "errors"
+
+func joinErrors(errs ...error) error {
+	return errors.Join(errs...)
+}
@@ -0,0 +1,39 @@
+//go:build !go1.20
+
+package goz
+
+import "strings"
+
+// joinError joins errors like errors.Join, which requires go1.20.
+type joinError struct {
+	errs []error
+}
+
+func joinErrors(errs ...error) error {
+	e := &joinError{}
+	for _, err := range errs {
+		if err != nil {
+			e.errs = append(e.errs, err)
+		}
+	}
+
+	if len(e.errs) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *joinError) Error() string {
+	var buf strings.Builder
+	for i, err := range e.errs {
+		if i > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(err.Error())
+	}
+	return buf.String()
+}
+
+func (e *joinError) Unwrap() []error {
+	return e.errs
+}
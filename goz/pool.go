@@ -0,0 +1,291 @@
+package goz
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/welllog/golib/ringz"
+)
+
+const defaultQueueSize = 1024
+
+var (
+	// ErrPoolClosed reports that the pool is shut down, or a queued task is discarded by Stop.
+	ErrPoolClosed = errors.New("goz: pool is closed")
+	// ErrPoolFull reports that the task is rejected by a full queue.
+	ErrPoolFull = errors.New("goz: pool queue is full")
+)
+
+// RejectPolicy is the policy of submitting a task to a full queue.
+type RejectPolicy int
+
+const (
+	// RejectBlock blocks the submitter until the queue has room, the pool is shut down or the context is done.
+	RejectBlock RejectPolicy = iota
+	// RejectDrop rejects the task with ErrPoolFull.
+	RejectDrop
+	// RejectCallerRuns runs the task in the goroutine of the submitter.
+	RejectCallerRuns
+)
+
+// PoolConfig configures a Pool.
+type PoolConfig struct {
+	// Workers number of workers. Default is runtime.NumCPU()
+	Workers int
+	// QueueSize capacity of the task queue. Default is 1024
+	QueueSize int
+	// Reject policy of submitting a task to a full queue. Default is RejectBlock
+	Reject RejectPolicy
+}
+
+// PoolStats is the statistics of a Pool.
+type PoolStats struct {
+	// Workers number of workers
+	Workers int
+	// Running number of running tasks
+	Running int64
+	// Queued number of queued tasks
+	Queued int
+	// Submitted number of accepted tasks, including the tasks run by the submitters
+	Submitted uint64
+	// Completed number of finished tasks, including the failed tasks
+	Completed uint64
+	// Failed number of tasks returning an error or panicking
+	Failed uint64
+	// Rejected number of tasks rejected by a full queue
+	Rejected uint64
+	// CallerRuns number of tasks run by the submitters
+	CallerRuns uint64
+}
+
+// Pool runs tasks by a fixed number of long-lived workers pulling from a bounded queue.
+// Tasks are submitted by Submit, panics of the tasks are recovered and returned as *PanicError.
+type Pool struct {
+	cfg   PoolConfig
+	queue ringz.SyncRing[func(discard bool) error]
+	// slots bounds the queued tasks, ready signals the workers once per queued task
+	slots chan struct{}
+	ready chan struct{}
+	quit  chan struct{}
+	once  sync.Once
+	wg    sync.WaitGroup
+
+	mu      sync.RWMutex
+	closed  bool
+	discard int32
+
+	running    int64
+	submitted  uint64
+	completed  uint64
+	failed     uint64
+	rejected   uint64
+	callerRuns uint64
+}
+
+// Future is the result of a task submitted to a Pool.
+type Future[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+// NewPool creates a new Pool and starts its workers.
+func NewPool(cfg PoolConfig) *Pool {
+	if cfg.Workers <= 0 {
+		cfg.Workers = runtime.NumCPU()
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultQueueSize
+	}
+
+	p := &Pool{
+		cfg:   cfg,
+		queue: ringz.NewSync[func(discard bool) error](cfg.QueueSize),
+		slots: make(chan struct{}, cfg.QueueSize),
+		ready: make(chan struct{}, cfg.QueueSize),
+		quit:  make(chan struct{}),
+	}
+
+	p.wg.Add(cfg.Workers)
+	for i := 0; i < cfg.Workers; i++ {
+		go p.work()
+	}
+
+	return p
+}
+
+// Submit submits fn to the pool, fn is called with ctx by a worker.
+// ctx also bounds the wait of RejectBlock, the context error is returned once it is done.
+// ErrPoolFull is returned if the task is rejected by RejectDrop, and ErrPoolClosed if the pool is shut down.
+func Submit[T any](ctx context.Context, p *Pool, fn func(ctx context.Context) (T, error)) (*Future[T], error) {
+	f := &Future[T]{done: make(chan struct{})}
+	task := func(discard bool) error {
+		defer close(f.done)
+		if discard {
+			f.err = ErrPoolClosed
+		} else {
+			f.value, f.err = runTask(ctx, fn)
+		}
+		return f.err
+	}
+
+	if err := p.submit(ctx, task); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Get waits for the task to finish and returns its result, or the context error once ctx is done.
+func (f *Future[T]) Get(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// Done returns a channel which is closed once the task finishes.
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Shutdown stops accepting tasks, and waits for the queued and running tasks to finish.
+// If ctx is done first, the context error is returned and the workers keep draining the queue.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.close()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stop stops accepting tasks, discards the queued tasks with ErrPoolClosed, and waits for the running tasks.
+func (p *Pool) Stop() {
+	atomic.StoreInt32(&p.discard, 1)
+	p.close()
+	p.wg.Wait()
+}
+
+// Stats returns the statistics of the pool.
+func (p *Pool) Stats() PoolStats {
+	return PoolStats{
+		Workers:    p.cfg.Workers,
+		Running:    atomic.LoadInt64(&p.running),
+		Queued:     len(p.slots),
+		Submitted:  atomic.LoadUint64(&p.submitted),
+		Completed:  atomic.LoadUint64(&p.completed),
+		Failed:     atomic.LoadUint64(&p.failed),
+		Rejected:   atomic.LoadUint64(&p.rejected),
+		CallerRuns: atomic.LoadUint64(&p.callerRuns),
+	}
+}
+
+func (p *Pool) submit(ctx context.Context, task func(discard bool) error) error {
+	callerRuns, err := p.enqueue(ctx, task)
+	if callerRuns {
+		atomic.AddUint64(&p.submitted, 1)
+		atomic.AddUint64(&p.callerRuns, 1)
+		p.run(task, false)
+	}
+	return err
+}
+
+// enqueue queues the task by the reject policy, it reports whether the task should be run by the submitter.
+func (p *Pool) enqueue(ctx context.Context, task func(discard bool) error) (bool, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return false, ErrPoolClosed
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		switch p.cfg.Reject {
+		case RejectDrop:
+			atomic.AddUint64(&p.rejected, 1)
+			return false, ErrPoolFull
+		case RejectCallerRuns:
+			return true, nil
+		default:
+			select {
+			case p.slots <- struct{}{}:
+			case <-p.quit:
+				return false, ErrPoolClosed
+			case <-ctx.Done():
+				return false, ctx.Err()
+			}
+		}
+	}
+
+	atomic.AddUint64(&p.submitted, 1)
+	// the slot guarantees the room of the ring, PushWait only waits for the concurrent pushes
+	p.queue.PushWait(task, -1)
+	p.ready <- struct{}{}
+	return false, nil
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+
+	for range p.ready {
+		task, _ := p.queue.PopWait(-1)
+		<-p.slots
+		p.run(task, atomic.LoadInt32(&p.discard) == 1)
+	}
+}
+
+func (p *Pool) run(task func(discard bool) error, discard bool) {
+	if discard {
+		_ = task(true)
+		return
+	}
+
+	atomic.AddInt64(&p.running, 1)
+	err := task(false)
+	atomic.AddInt64(&p.running, -1)
+
+	atomic.AddUint64(&p.completed, 1)
+	if err != nil {
+		atomic.AddUint64(&p.failed, 1)
+	}
+}
+
+// close stops accepting tasks, the workers exit once the queue is drained.
+func (p *Pool) close() {
+	p.once.Do(func() {
+		// wake the blocked submitters before waiting for them
+		close(p.quit)
+
+		p.mu.Lock()
+		p.closed = true
+		p.mu.Unlock()
+
+		close(p.ready)
+	})
+}
+
+func runTask[T any](ctx context.Context, fn func(ctx context.Context) (T, error)) (v T, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = newPanicError(p)
+		}
+	}()
+
+	return fn(ctx)
+}
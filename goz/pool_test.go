@@ -0,0 +1,217 @@
+package goz
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPool_Submit(t *testing.T) {
+	p := NewPool(PoolConfig{Workers: 4, QueueSize: 16})
+	defer p.Stop()
+
+	ctx := context.Background()
+	futures := make([]*Future[int], 100)
+	for i := range futures {
+		i := i
+		f, err := Submit(ctx, p, func(ctx context.Context) (int, error) {
+			return i * 2, nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		futures[i] = f
+	}
+
+	for i, f := range futures {
+		v, err := f.Get(ctx)
+		if err != nil || v != i*2 {
+			t.Fatalf("expected %d, got %d %v", i*2, v, err)
+		}
+	}
+
+	errTask := errors.New("task")
+	f, _ := Submit(ctx, p, func(ctx context.Context) (string, error) { return "", errTask })
+	if _, err := f.Get(ctx); err != errTask {
+		t.Errorf("expected the task error, got %v", err)
+	}
+
+	f, _ = Submit(ctx, p, func(ctx context.Context) (string, error) { panic("boom") })
+	var pe *PanicError
+	if _, err := f.Get(ctx); !errors.As(err, &pe) || pe.Value != "boom" {
+		t.Errorf("expected *PanicError, got %v", err)
+	}
+
+	<-f.Done()
+	stats := p.Stats()
+	if stats.Workers != 4 || stats.Submitted != 102 || stats.Completed != 102 || stats.Failed != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestPool_Reject(t *testing.T) {
+	ctx := context.Background()
+
+	newBlockedPool := func(reject RejectPolicy) (*Pool, chan struct{}) {
+		release := make(chan struct{})
+		p := NewPool(PoolConfig{Workers: 1, QueueSize: 1, Reject: reject})
+
+		started := make(chan struct{})
+		_, _ = Submit(ctx, p, func(ctx context.Context) (int, error) {
+			close(started)
+			<-release
+			return 0, nil
+		})
+		<-started
+		// fills the queue
+		_, _ = Submit(ctx, p, func(ctx context.Context) (int, error) { return 0, nil })
+		return p, release
+	}
+
+	t.Run("drop", func(t *testing.T) {
+		p, release := newBlockedPool(RejectDrop)
+		defer p.Stop()
+		defer close(release)
+
+		if _, err := Submit(ctx, p, func(ctx context.Context) (int, error) { return 0, nil }); err != ErrPoolFull {
+			t.Errorf("expected ErrPoolFull, got %v", err)
+		}
+		if stats := p.Stats(); stats.Rejected != 1 || stats.Queued != 1 || stats.Running != 1 {
+			t.Errorf("unexpected stats: %+v", stats)
+		}
+	})
+
+	t.Run("caller runs", func(t *testing.T) {
+		p, release := newBlockedPool(RejectCallerRuns)
+		defer p.Stop()
+		defer close(release)
+
+		done := make(chan struct{})
+		var inCaller bool
+		f, err := Submit(ctx, p, func(ctx context.Context) (int, error) {
+			select {
+			case <-done:
+			default:
+				inCaller = true
+			}
+			return 1, nil
+		})
+		close(done)
+
+		if err != nil || !inCaller {
+			t.Fatalf("expected the task to run in the caller, got %v", err)
+		}
+		if v, _ := f.Get(ctx); v != 1 || p.Stats().CallerRuns != 1 {
+			t.Errorf("unexpected result %d, stats %+v", v, p.Stats())
+		}
+	})
+
+	t.Run("block", func(t *testing.T) {
+		p, release := newBlockedPool(RejectBlock)
+		defer p.Stop()
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		if _, err := Submit(timeoutCtx, p, func(ctx context.Context) (int, error) { return 0, nil }); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded, got %v", err)
+		}
+
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			close(release)
+		}()
+
+		f, err := Submit(ctx, p, func(ctx context.Context) (int, error) { return 2, nil })
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if v, _ := f.Get(ctx); v != 2 {
+			t.Errorf("expected 2, got %d", v)
+		}
+	})
+}
+
+func TestPool_Shutdown(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("drain", func(t *testing.T) {
+		p := NewPool(PoolConfig{Workers: 2, QueueSize: 64})
+		var n int32
+		for i := 0; i < 50; i++ {
+			_, _ = Submit(ctx, p, func(ctx context.Context) (int, error) {
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&n, 1)
+				return 0, nil
+			})
+		}
+
+		if err := p.Shutdown(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if n != 50 {
+			t.Errorf("expected all 50 tasks to be drained, got %d", n)
+		}
+		if _, err := Submit(ctx, p, func(ctx context.Context) (int, error) { return 0, nil }); err != ErrPoolClosed {
+			t.Errorf("expected ErrPoolClosed, got %v", err)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		p := NewPool(PoolConfig{Workers: 1})
+		release := make(chan struct{})
+		_, _ = Submit(ctx, p, func(ctx context.Context) (int, error) {
+			<-release
+			return 0, nil
+		})
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		if err := p.Shutdown(timeoutCtx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded, got %v", err)
+		}
+		close(release)
+		if err := p.Shutdown(ctx); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		p := NewPool(PoolConfig{Workers: 1, QueueSize: 8})
+		release := make(chan struct{})
+		started := make(chan struct{})
+		running, _ := Submit(ctx, p, func(ctx context.Context) (int, error) {
+			close(started)
+			<-release
+			return 1, nil
+		})
+		<-started
+
+		var queued []*Future[int]
+		for i := 0; i < 5; i++ {
+			f, _ := Submit(ctx, p, func(ctx context.Context) (int, error) { return 2, nil })
+			queued = append(queued, f)
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Stop()
+		}()
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		if v, err := running.Get(ctx); v != 1 || err != nil {
+			t.Errorf("expected the running task to finish, got %d %v", v, err)
+		}
+		for _, f := range queued {
+			if _, err := f.Get(ctx); err != ErrPoolClosed {
+				t.Errorf("expected ErrPoolClosed of a queued task, got %v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,430 @@
+package httpz
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/welllog/golib/hashz"
+)
+
+const (
+	defaultTokenRefreshBefore = 30 * time.Second
+	defaultTokenFetchTimeout  = 30 * time.Second
+
+	// HeaderTimestamp header of the signing timestamp set by HMACSigner.
+	HeaderTimestamp = "X-Timestamp"
+)
+
+// ErrNoToken reports that the token endpoint returned no access token.
+var ErrNoToken = errors.New("httpz: no access token in token response")
+
+// BearerAuth returns the middleware which sets the static bearer token, an existing Authorization header is kept.
+func BearerAuth(token string) Middleware {
+	return func(next DoFunc) DoFunc {
+		return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			if req.Header.Get("Authorization") == "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// BasicAuth returns the middleware which sets the basic auth, an existing Authorization header is kept.
+func BasicAuth(username, password string) Middleware {
+	return func(next DoFunc) DoFunc {
+		return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			if req.Header.Get("Authorization") == "" {
+				req.SetBasicAuth(username, password)
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// Token is an OAuth2 access token.
+type Token struct {
+	// AccessToken the access token
+	AccessToken string `json:"access_token"`
+	// TokenType the token type, Bearer if empty
+	TokenType string `json:"token_type,omitempty"`
+	// ExpiresIn lifetime in seconds of the token returned by the token endpoint
+	ExpiresIn int64 `json:"expires_in,omitempty"`
+	// Expiry the expiry time of the token, zero means no expiry
+	Expiry time.Time `json:"-"`
+}
+
+// Valid reports whether the token is not expired at now.
+func (t *Token) Valid(now time.Time) bool {
+	return t != nil && t.AccessToken != "" && (t.Expiry.IsZero() || now.Before(t.Expiry))
+}
+
+// header returns the value of the Authorization header.
+func (t *Token) header() string {
+	typ := t.TokenType
+	if typ == "" || strings.EqualFold(typ, "bearer") {
+		typ = "Bearer"
+	}
+	return typ + " " + t.AccessToken
+}
+
+// TokenSource returns access tokens.
+type TokenSource interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+// TokenInvalidator is implemented by the token sources which can drop a rejected token.
+type TokenInvalidator interface {
+	// Invalidate drops the cached token if it is the access token
+	Invalidate(accessToken string)
+}
+
+// TokenAuth returns the middleware which sets the token of the source to the Authorization header.
+// if the response is 401 and the request body can be rebuilt, the request is retried once with a new token
+// when the source implements TokenInvalidator.
+func TokenAuth(src TokenSource) Middleware {
+	return func(next DoFunc) DoFunc {
+		return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			token, err := src.Token(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			req.Header.Set("Authorization", token.header())
+			resp, err := next(ctx, req)
+			if err != nil || resp.StatusCode != http.StatusUnauthorized {
+				return resp, err
+			}
+
+			invalidator, ok := src.(TokenInvalidator)
+			if !ok || !canRebuildBody(req) {
+				return resp, err
+			}
+
+			invalidator.Invalidate(token.AccessToken)
+			token, err = src.Token(ctx)
+			if err != nil || rebuildBody(req) != nil {
+				// keep the 401 response
+				return resp, nil
+			}
+
+			_ = resp.Body.Close()
+			req.Header.Set("Authorization", token.header())
+			return next(ctx, req)
+		}
+	}
+}
+
+// ClientCredentialsConfig configures a ClientCredentials token source.
+type ClientCredentialsConfig struct {
+	// TokenURL url of the token endpoint
+	TokenURL string
+	// ClientID client id
+	ClientID string
+	// ClientSecret client secret
+	ClientSecret string
+	// Scopes requested scopes
+	Scopes []string
+	// EndpointParams additional parameters of the token request
+	EndpointParams url.Values
+	// AuthInParams sends the client id and secret in the request body instead of the basic auth
+	AuthInParams bool
+	// Client sends the token requests. Default is NewClient()
+	Client *Client
+	// RefreshBefore the token is refreshed in background this long before it expires,
+	// the cached token is still used meanwhile. Default is 30s
+	RefreshBefore time.Duration
+	// Timeout timeout of a token request. Default is 30s
+	Timeout time.Duration
+}
+
+// ClientCredentials is a TokenSource of the OAuth2 client credentials grant.
+// The token is cached and refreshed before expiry, concurrent refreshes are merged into one token request.
+type ClientCredentials struct {
+	cfg ClientCredentialsConfig
+
+	mu         sync.Mutex
+	token      *Token
+	refreshing *tokenCall
+}
+
+type tokenCall struct {
+	done  chan struct{}
+	token *Token
+	err   error
+}
+
+// NewClientCredentials creates a new ClientCredentials token source.
+func NewClientCredentials(cfg ClientCredentialsConfig) *ClientCredentials {
+	if cfg.Client == nil {
+		cfg.Client = NewClient()
+	}
+	if cfg.RefreshBefore <= 0 {
+		cfg.RefreshBefore = defaultTokenRefreshBefore
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTokenFetchTimeout
+	}
+
+	return &ClientCredentials{cfg: cfg}
+}
+
+// Token returns the cached token, or fetches a new one if the cached token is expired.
+func (s *ClientCredentials) Token(ctx context.Context) (*Token, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	token := s.token
+	if token.Valid(now) {
+		if !token.Expiry.IsZero() && !now.Before(token.Expiry.Add(-s.cfg.RefreshBefore)) {
+			// refresh in background while the token is still valid
+			s.refresh()
+		}
+		s.mu.Unlock()
+		return token, nil
+	}
+
+	call := s.refresh()
+	s.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.token, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Invalidate drops the cached token if it is the access token.
+func (s *ClientCredentials) Invalidate(accessToken string) {
+	s.mu.Lock()
+	if s.token != nil && s.token.AccessToken == accessToken {
+		s.token = nil
+	}
+	s.mu.Unlock()
+}
+
+// refresh starts a token request if there is none in flight, the caller must hold the lock.
+func (s *ClientCredentials) refresh() *tokenCall {
+	if s.refreshing != nil {
+		return s.refreshing
+	}
+
+	call := &tokenCall{done: make(chan struct{})}
+	s.refreshing = call
+
+	go func() {
+		// the token request is not bound to the caller, other callers may be waiting for it
+		ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+		call.token, call.err = s.fetch(ctx)
+		cancel()
+
+		s.mu.Lock()
+		if call.err == nil {
+			s.token = call.token
+		}
+		s.refreshing = nil
+		s.mu.Unlock()
+
+		close(call.done)
+	}()
+
+	return call
+}
+
+func (s *ClientCredentials) fetch(ctx context.Context) (*Token, error) {
+	params := url.Values{"grant_type": {"client_credentials"}}
+	if len(s.cfg.Scopes) > 0 {
+		params.Set("scope", strings.Join(s.cfg.Scopes, " "))
+	}
+	for k, vs := range s.cfg.EndpointParams {
+		params[k] = append(params[k], vs...)
+	}
+	if s.cfg.AuthInParams {
+		params.Set("client_id", s.cfg.ClientID)
+		params.Set("client_secret", s.cfg.ClientSecret)
+	}
+
+	headers := map[string]string{"Content-Type": MIMEForm, "Accept": MIMEJSON}
+	req, err := newRequest(ctx, http.MethodPost, s.cfg.TokenURL, headers, params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if !s.cfg.AuthInParams {
+		req.SetBasicAuth(url.QueryEscape(s.cfg.ClientID), url.QueryEscape(s.cfg.ClientSecret))
+	}
+
+	begin := time.Now()
+	token, err := Do[Token](s.cfg.Client, req, JSONCodec{})
+	if err != nil {
+		return nil, err
+	}
+
+	if token.AccessToken == "" {
+		return nil, ErrNoToken
+	}
+	if token.ExpiresIn > 0 {
+		token.Expiry = begin.Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+
+	return &token, nil
+}
+
+// HMACConfig configures an HMACSigner.
+type HMACConfig struct {
+	// KeyID id of the key, sent with the signature
+	KeyID string
+	// Secret secret key of the HMAC
+	Secret []byte
+	// Hash hash function of the HMAC. Default is sha256.New
+	Hash func() hash.Hash
+	// SignedHeaders header names included in the canonical string, e.g. Host, Content-Type
+	SignedHeaders []string
+}
+
+// HMACSigner signs requests with the HMAC of a canonical string built from the method, path, sorted query,
+// signed headers, timestamp and body hash. The signature is sent in the Authorization header as
+//
+//	HMAC keyId="<KeyID>", headers="<signed headers>", signature="<hex signature>"
+//
+// and the unix timestamp in seconds is sent in the X-Timestamp header.
+type HMACSigner struct {
+	cfg HMACConfig
+}
+
+// NewHMACSigner creates a new HMACSigner.
+func NewHMACSigner(cfg HMACConfig) *HMACSigner {
+	if cfg.Hash == nil {
+		cfg.Hash = sha256.New
+	}
+
+	headers := make([]string, len(cfg.SignedHeaders))
+	for i, h := range cfg.SignedHeaders {
+		headers[i] = strings.ToLower(h)
+	}
+	sort.Strings(headers)
+	cfg.SignedHeaders = headers
+
+	return &HMACSigner{cfg: cfg}
+}
+
+// Middleware returns the middleware which signs each request.
+func (s *HMACSigner) Middleware() Middleware {
+	return func(next DoFunc) DoFunc {
+		return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			if err := s.Sign(req); err != nil {
+				return nil, err
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// Sign sets the timestamp and the signature headers of the request.
+// A request body without GetBody is read into memory, and GetBody is set so it can be rebuilt.
+func (s *HMACSigner) Sign(req *http.Request) error {
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(time.Now().Unix(), 10))
+
+	signature, err := s.Signature(req)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", `HMAC keyId="`+s.cfg.KeyID+`", headers="`+
+		strings.Join(s.cfg.SignedHeaders, " ")+`", signature="`+signature+`"`)
+	return nil
+}
+
+// Signature returns the hex signature of the request with its current timestamp header,
+// the receiver can verify a request by comparing it with the sent signature through hmac.Equal.
+func (s *HMACSigner) Signature(req *http.Request) (string, error) {
+	canonical, err := s.CanonicalString(req)
+	if err != nil {
+		return "", err
+	}
+	return hashz.HmacToString(s.cfg.Secret, canonical, s.cfg.Hash), nil
+}
+
+// CanonicalString returns the string to sign of the request, the lines are
+// method, escaped path, sorted query, signed headers as "name:value", timestamp and hex sha256 of the body.
+func (s *HMACSigner) CanonicalString(req *http.Request) (string, error) {
+	bodyHash, err := requestBodyHash(req)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(req.Method)
+	sb.WriteByte('\n')
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	sb.WriteString(path)
+	sb.WriteByte('\n')
+	// url.Values.Encode sorts by key, the values of a key keep their order
+	sb.WriteString(req.URL.Query().Encode())
+	sb.WriteByte('\n')
+	for _, h := range s.cfg.SignedHeaders {
+		value := req.Header.Get(h)
+		if h == "host" {
+			value = req.Host
+			if value == "" {
+				value = req.URL.Host
+			}
+		}
+		sb.WriteString(h)
+		sb.WriteByte(':')
+		sb.WriteString(strings.TrimSpace(value))
+		sb.WriteByte('\n')
+	}
+	sb.WriteString(req.Header.Get(HeaderTimestamp))
+	sb.WriteByte('\n')
+	sb.WriteString(bodyHash)
+
+	return sb.String(), nil
+}
+
+// requestBodyHash returns the hex sha256 of the request body without consuming it.
+func requestBodyHash(req *http.Request) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return hashz.Sha256ToString(""), nil
+	}
+
+	if req.GetBody == nil {
+		body, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return "", err
+		}
+
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+		return hashz.Sha256ToString(body), nil
+	}
+
+	rc, err := req.GetBody()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	h, err := hashz.Sha256Stream(rc)
+	if err != nil {
+		return "", err
+	}
+	return string(h), nil
+}
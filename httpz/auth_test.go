@@ -0,0 +1,185 @@
+package httpz
+
+import (
+	"context"
+	"crypto/hmac"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStaticAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	out, _ := Get[string](ctx, NewClient(WithMiddleware(BearerAuth("abc"))), server.URL, nil, nil)
+	if out != "Bearer abc" {
+		t.Errorf("unexpected bearer auth: %s", out)
+	}
+
+	out, _ = Get[string](ctx, NewClient(WithMiddleware(BasicAuth("u", "p"))), server.URL, nil, nil)
+	if out != "Basic dTpw" {
+		t.Errorf("unexpected basic auth: %s", out)
+	}
+
+	out, _ = Get[string](ctx, NewClient(WithMiddleware(BasicAuth("u", "p"))), server.URL,
+		map[string]string{"Authorization": "custom"}, nil)
+	if out != "custom" {
+		t.Errorf("expected the existing header to be kept, got %s", out)
+	}
+}
+
+func TestClientCredentials(t *testing.T) {
+	var issued int32
+	var revoked sync.Map
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			id, secret, _ := r.BasicAuth()
+			_ = r.ParseForm()
+			if id != "id" || secret != "s%26" || r.PostForm.Get("grant_type") != "client_credentials" ||
+				r.PostForm.Get("scope") != "read write" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			time.Sleep(20 * time.Millisecond)
+			n := atomic.AddInt32(&issued, 1)
+			w.Header().Set("Content-Type", MIMEJSON)
+			_, _ = w.Write([]byte(`{"access_token":"t` + strconv.Itoa(int(n)) + `","token_type":"bearer","expires_in":3600}`))
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if _, ok := revoked.Load(token); ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(token))
+	}))
+	defer server.Close()
+
+	src := NewClientCredentials(ClientCredentialsConfig{
+		TokenURL:     server.URL + "/token",
+		ClientID:     "id",
+		ClientSecret: "s&",
+		Scopes:       []string{"read", "write"},
+	})
+	client := NewClient(WithMiddleware(TokenAuth(src)))
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out, err := Get[string](ctx, client, server.URL+"/api", nil, nil)
+			if err != nil || out != "t1" {
+				t.Errorf("unexpected response: %s %v", out, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if atomic.LoadInt32(&issued) != 1 {
+		t.Errorf("expected a single token request, got %d", issued)
+	}
+
+	revoked.Store("t1", true)
+	out, err := Post[string](ctx, client, server.URL+"/api", nil, "body", nil)
+	if err != nil || out != "t2" {
+		t.Errorf("expected retry with a new token, got %s %v", out, err)
+	}
+
+	token, _ := src.Token(ctx)
+	if token.AccessToken != "t2" || time.Until(token.Expiry) < 59*time.Minute {
+		t.Errorf("unexpected token: %+v", token)
+	}
+}
+
+func TestClientCredentials_Refresh(t *testing.T) {
+	var issued int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&issued, 1)
+		_, _ = w.Write([]byte(`{"access_token":"t` + strconv.Itoa(int(n)) + `","expires_in":2}`))
+	}))
+	defer server.Close()
+
+	src := NewClientCredentials(ClientCredentialsConfig{
+		TokenURL:      server.URL,
+		AuthInParams:  true,
+		RefreshBefore: 1900 * time.Millisecond,
+	})
+
+	ctx := context.Background()
+	token, err := src.Token(ctx)
+	if err != nil || token.AccessToken != "t1" {
+		t.Fatalf("unexpected token: %+v %v", token, err)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	// the cached token is returned while it is refreshed in background
+	if token, _ = src.Token(ctx); token.AccessToken != "t1" {
+		t.Errorf("expected the cached token, got %+v", token)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if token, _ = src.Token(ctx); token.AccessToken != "t2" {
+		t.Errorf("expected the refreshed token, got %+v", token)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	src.Invalidate("t2")
+	if _, err = src.Token(ctx); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestHMACSigner(t *testing.T) {
+	signer := NewHMACSigner(HMACConfig{KeyID: "k1", Secret: []byte("secret"), SignedHeaders: []string{"Host", "Content-Type"}})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		i := strings.Index(auth, `signature="`)
+		if i < 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		got := strings.TrimSuffix(auth[i+len(`signature="`):], `"`)
+		want, err := signer.Signature(r)
+		if err != nil || !hmac.Equal([]byte(got), []byte(want)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(auth[:i]))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithMiddleware(signer.Middleware()))
+	out, err := Post[string](context.Background(), client, server.URL+"/a%20b?z=1&a=2&a=1", nil,
+		strings.NewReader(`{"x":1}`), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != `HMAC keyId="k1", headers="content-type host", ` {
+		t.Errorf("unexpected auth header: %s", out)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/p?b=2&a=1", nil)
+	req.Header.Set(HeaderTimestamp, "100")
+	canonical, _ := signer.CanonicalString(req)
+	want := "GET\n/p\na=1&b=2\ncontent-type:\nhost:example.com\n100\n" +
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	if canonical != want {
+		t.Errorf("unexpected canonical string: %q", canonical)
+	}
+}
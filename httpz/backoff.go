@@ -0,0 +1,168 @@
+package httpz
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BackoffFunc returns the delay before the given retry attempt.
+// attempt starts at 1 for the first retry, prev is the delay used before the previous retry (0 for the first retry).
+// The returned delay will be capped by maxDelay when maxDelay is greater than 0.
+type BackoffFunc func(attempt int, minDelay, maxDelay, prev time.Duration) time.Duration
+
+// ExponentialBackoff returns minDelay * 2^(attempt-1) without jitter.
+func ExponentialBackoff(attempt int, minDelay, maxDelay, prev time.Duration) time.Duration {
+	return expDelay(attempt, minDelay, maxDelay)
+}
+
+// FullJitterBackoff returns a random delay in [0, minDelay * 2^(attempt-1)].
+func FullJitterBackoff(attempt int, minDelay, maxDelay, prev time.Duration) time.Duration {
+	d := expDelay(attempt, minDelay, maxDelay)
+	if d <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// EqualJitterBackoff returns a random delay in [d/2, d], d is minDelay * 2^(attempt-1).
+func EqualJitterBackoff(attempt int, minDelay, maxDelay, prev time.Duration) time.Duration {
+	d := expDelay(attempt, minDelay, maxDelay)
+	if d <= 0 {
+		return 0
+	}
+
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+// DecorrelatedJitterBackoff returns a random delay in [minDelay, prev * 3].
+func DecorrelatedJitterBackoff(attempt int, minDelay, maxDelay, prev time.Duration) time.Duration {
+	if minDelay <= 0 {
+		return 0
+	}
+
+	if prev < minDelay {
+		prev = minDelay
+	}
+
+	upper := prev * 3
+	if upper < prev || (maxDelay > 0 && upper > maxDelay) { // overflow or exceed max
+		upper = maxDelay
+		if upper <= 0 {
+			upper = prev
+		}
+	}
+
+	if upper <= minDelay {
+		return minDelay
+	}
+
+	return minDelay + time.Duration(rand.Int63n(int64(upper-minDelay)+1))
+}
+
+func expDelay(attempt int, minDelay, maxDelay time.Duration) time.Duration {
+	if minDelay <= 0 || attempt <= 0 {
+		return 0
+	}
+
+	d := minDelay
+	for i := 1; i < attempt; i++ {
+		next := d * 2
+		if next < d { // overflow
+			if maxDelay > 0 {
+				return maxDelay
+			}
+			return d
+		}
+
+		d = next
+		if maxDelay > 0 && d >= maxDelay {
+			return maxDelay
+		}
+	}
+
+	return d
+}
+
+// retryDelay returns the delay before next retry attempt.
+// Retry-After header of 429 and 503 responses will be respected if it asks for a longer delay.
+func retryDelay(policy *RetryPolicy, attempt int, prev time.Duration, resp *http.Response) time.Duration {
+	backoff := policy.Backoff
+	if backoff == nil {
+		backoff = ExponentialBackoff
+	}
+
+	d := backoff(attempt, policy.MinRetryDelay, policy.MaxRetryDelay, prev)
+	if !policy.IgnoreRetryAfter {
+		if after, ok := parseRetryAfter(resp); ok && after > d {
+			d = after
+		}
+	}
+
+	if policy.MaxRetryDelay > 0 && d > policy.MaxRetryDelay {
+		d = policy.MaxRetryDelay
+	}
+
+	if d < 0 {
+		d = 0
+	}
+
+	return d
+}
+
+// parseRetryAfter parses the Retry-After header of 429 and 503 responses.
+// Both delay-seconds and HTTP-date forms are supported.
+func parseRetryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+
+	v := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if v == "" {
+		return 0, false
+	}
+
+	if sec, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if sec < 0 {
+			return 0, false
+		}
+		return time.Duration(sec) * time.Second, true
+	}
+
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+
+	d := time.Until(t)
+	if d < 0 {
+		d = 0
+	}
+	return d, true
+}
+
+// sleepContext waits for the duration d, and returns ctx.Err() as soon as the ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
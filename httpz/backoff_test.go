@@ -0,0 +1,191 @@
+package httpz
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+		expected time.Duration
+	}{
+		{1, 10 * time.Millisecond, 0, 10 * time.Millisecond},
+		{2, 10 * time.Millisecond, 0, 20 * time.Millisecond},
+		{4, 10 * time.Millisecond, 0, 80 * time.Millisecond},
+		{4, 10 * time.Millisecond, 50 * time.Millisecond, 50 * time.Millisecond},
+		{100, time.Second, time.Minute, time.Minute},
+		{1, 0, time.Second, 0},
+	}
+
+	for _, tt := range tests {
+		d := ExponentialBackoff(tt.attempt, tt.min, tt.max, 0)
+		if d != tt.expected {
+			t.Errorf("attempt %d: expected %v, got %v", tt.attempt, tt.expected, d)
+		}
+	}
+
+	if d := ExponentialBackoff(200, time.Second, 0, 0); d <= 0 {
+		t.Errorf("expected positive delay on overflow, got %v", d)
+	}
+}
+
+func TestJitterBackoff(t *testing.T) {
+	minDelay := 10 * time.Millisecond
+	maxDelay := 100 * time.Millisecond
+
+	for i := 0; i < 100; i++ {
+		attempt := i%6 + 1
+		exp := ExponentialBackoff(attempt, minDelay, maxDelay, 0)
+
+		if d := FullJitterBackoff(attempt, minDelay, maxDelay, 0); d < 0 || d > exp {
+			t.Fatalf("full jitter: expected delay in [0, %v], got %v", exp, d)
+		}
+
+		if d := EqualJitterBackoff(attempt, minDelay, maxDelay, 0); d < exp/2 || d > exp {
+			t.Fatalf("equal jitter: expected delay in [%v, %v], got %v", exp/2, exp, d)
+		}
+
+		prev := time.Duration(i) * time.Millisecond
+		d := DecorrelatedJitterBackoff(attempt, minDelay, maxDelay, prev)
+		if d < minDelay || d > maxDelay {
+			t.Fatalf("decorrelated jitter: expected delay in [%v, %v], got %v", minDelay, maxDelay, d)
+		}
+		if prev >= minDelay && d > prev*3 {
+			t.Fatalf("decorrelated jitter: expected delay <= %v, got %v", prev*3, d)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	newResp := func(code int, v string) *http.Response {
+		resp := &http.Response{StatusCode: code, Header: http.Header{}}
+		if v != "" {
+			resp.Header.Set("Retry-After", v)
+		}
+		return resp
+	}
+
+	if d, ok := parseRetryAfter(newResp(http.StatusTooManyRequests, "3")); !ok || d != 3*time.Second {
+		t.Errorf("expected 3s, got %v %v", d, ok)
+	}
+
+	date := time.Now().Add(5 * time.Second).UTC().Format(http.TimeFormat)
+	if d, ok := parseRetryAfter(newResp(http.StatusServiceUnavailable, date)); !ok || d <= 3*time.Second || d > 5*time.Second {
+		t.Errorf("expected about 5s, got %v %v", d, ok)
+	}
+
+	if _, ok := parseRetryAfter(newResp(http.StatusInternalServerError, "3")); ok {
+		t.Error("expected Retry-After to be ignored on 500 status")
+	}
+
+	if _, ok := parseRetryAfter(newResp(http.StatusTooManyRequests, "invalid")); ok {
+		t.Error("expected invalid Retry-After to be ignored")
+	}
+
+	if _, ok := parseRetryAfter(nil); ok {
+		t.Error("expected nil response to be ignored")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+	resp.Header.Set("Retry-After", "2")
+
+	policy := RetryPolicy{MinRetryDelay: 10 * time.Millisecond}
+	if d := retryDelay(&policy, 1, 0, resp); d != 2*time.Second {
+		t.Errorf("expected Retry-After delay 2s, got %v", d)
+	}
+
+	policy.MaxRetryDelay = time.Second
+	if d := retryDelay(&policy, 1, 0, resp); d != time.Second {
+		t.Errorf("expected delay capped to 1s, got %v", d)
+	}
+
+	policy.IgnoreRetryAfter = true
+	if d := retryDelay(&policy, 1, 0, resp); d != 10*time.Millisecond {
+		t.Errorf("expected backoff delay 10ms, got %v", d)
+	}
+
+	policy.Backoff = func(attempt int, minDelay, maxDelay, prev time.Duration) time.Duration {
+		return time.Hour
+	}
+	if d := retryDelay(&policy, 1, 0, resp); d != time.Second {
+		t.Errorf("expected custom backoff capped to 1s, got %v", d)
+	}
+}
+
+func TestClient_RetryAfter(t *testing.T) {
+	attempts := 0
+	var first time.Time
+	var second time.Duration
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			first = time.Now()
+			w.Header().Set("Retry-After", strconv.Itoa(1))
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		second = time.Since(first)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := client.DoWithRetry(req, RetryPolicy{
+		MaxRetries:    2,
+		MinRetryDelay: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", attempts)
+	}
+	if second < 900*time.Millisecond {
+		t.Errorf("expected retry to wait for Retry-After, waited %v", second)
+	}
+}
+
+func TestClient_RetryWaitCanceled(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithRetryPolicy(RetryPolicy{
+		MaxRetries:    3,
+		MinRetryDelay: 10 * time.Second,
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	begin := time.Now()
+	resp, err := client.Do(req)
+	if err != context.Canceled {
+		t.Errorf("expected context canceled, got %v", err)
+	}
+	if resp != nil {
+		t.Error("expected nil response")
+	}
+	if time.Since(begin) > time.Second {
+		t.Errorf("expected retry wait to stop on context done, took %v", time.Since(begin))
+	}
+	if attempts != 1 {
+		t.Errorf("expected 1 attempt, got %d", attempts)
+	}
+}
@@ -0,0 +1,459 @@
+package httpz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultEjectFailureThreshold = 3
+	defaultEjectDuration         = 30 * time.Second
+	defaultHashReplicas          = 100
+	defaultHealthCheckInterval   = 10 * time.Second
+	defaultHealthCheckTimeout    = 2 * time.Second
+)
+
+// ErrNoEndpoint reports that no endpoint of the pool is available.
+var ErrNoEndpoint = errors.New("httpz: no available endpoint")
+
+// BalanceStrategy is the strategy of picking an endpoint from an EndpointPool.
+type BalanceStrategy int
+
+const (
+	// RoundRobin picks the endpoints in turn.
+	RoundRobin BalanceStrategy = iota
+	// LeastInFlight picks the endpoint with the fewest in-flight requests.
+	LeastInFlight
+	// ConsistentHash picks the endpoint by the consistent hash of the request key.
+	ConsistentHash
+)
+
+type triedEndpointsKey struct{}
+
+// EndpointPoolConfig configures an EndpointPool.
+type EndpointPoolConfig struct {
+	// Endpoints base URLs of the endpoints
+	Endpoints []string
+	// Strategy strategy of picking an endpoint. Default is RoundRobin
+	Strategy BalanceStrategy
+	// KeyFunc returns the consistent hash key of the request. Default is the request path
+	KeyFunc func(req *http.Request) string
+	// Replicas virtual nodes of each endpoint on the consistent hash ring. Default is 100
+	Replicas int
+	// IsFailure failure judgment function of the ejection. Default is DefaultFailureFunc
+	IsFailure RetryableFunc
+	// FailureThreshold consecutive failures to eject an endpoint. Default is 3
+	FailureThreshold int
+	// EjectDuration duration of an ejection. Default is 30s
+	EjectDuration time.Duration
+	// HealthCheckPath path of the background health probes, joined to the endpoint URLs.
+	// Default is empty, means no health probe
+	HealthCheckPath string
+	// HealthCheckInterval interval of the health probes. Default is 10s
+	HealthCheckInterval time.Duration
+	// HealthCheckTimeout timeout of each health probe. Default is 2s
+	HealthCheckTimeout time.Duration
+	// IsHealthy health judgment function of the probes. Default is a 2xx response
+	IsHealthy func(resp *http.Response, err error) bool
+	// HealthClient sends the health probes. Default is http.DefaultClient
+	HealthClient *http.Client
+}
+
+// EndpointStats is the state of an endpoint.
+type EndpointStats struct {
+	// URL base URL of the endpoint
+	URL string
+	// InFlight number of in-flight requests, a request is in flight until its response body is closed
+	InFlight int64
+	// Failures consecutive failures
+	Failures int
+	// Ejected reports whether the endpoint is ejected by failures
+	Ejected bool
+	// Healthy reports whether the last health probe succeeded, it is true without health probes
+	Healthy bool
+}
+
+// EndpointPool balances the requests of a Client over several endpoints, it is set by WithEndpointPool.
+// Each attempt of a request picks an endpoint, the endpoints which failed the previous attempts of the request
+// are avoided unless no other endpoint is available. Endpoints are ejected for EjectDuration after
+// FailureThreshold consecutive failures, and taken out while the health probes fail.
+type EndpointPool struct {
+	cfg       EndpointPoolConfig
+	endpoints []*endpoint
+	ring      []ringNode
+	next      uint64
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type endpoint struct {
+	url      *url.URL
+	raw      string
+	inflight int64
+
+	// guarded by EndpointPool.mu
+	failures     int
+	ejectedUntil time.Time
+	unhealthy    bool
+}
+
+type ringNode struct {
+	hash     uint32
+	endpoint int
+}
+
+// triedEndpoints records the endpoints which failed the previous attempts of a request.
+type triedEndpoints struct {
+	failed []*endpoint
+}
+
+// NewEndpointPool creates a new EndpointPool, the health probes start if HealthCheckPath is set.
+func NewEndpointPool(cfg EndpointPoolConfig) (*EndpointPool, error) {
+	if len(cfg.Endpoints) == 0 {
+		return nil, errors.New("httpz: endpoint pool requires at least one endpoint")
+	}
+	if cfg.KeyFunc == nil {
+		cfg.KeyFunc = func(req *http.Request) string { return req.URL.Path }
+	}
+	if cfg.Replicas <= 0 {
+		cfg.Replicas = defaultHashReplicas
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = DefaultFailureFunc
+	}
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = defaultEjectFailureThreshold
+	}
+	if cfg.EjectDuration <= 0 {
+		cfg.EjectDuration = defaultEjectDuration
+	}
+	if cfg.HealthCheckInterval <= 0 {
+		cfg.HealthCheckInterval = defaultHealthCheckInterval
+	}
+	if cfg.HealthCheckTimeout <= 0 {
+		cfg.HealthCheckTimeout = defaultHealthCheckTimeout
+	}
+	if cfg.IsHealthy == nil {
+		cfg.IsHealthy = func(resp *http.Response, err error) bool {
+			return err == nil && resp.StatusCode >= 200 && resp.StatusCode <= 299
+		}
+	}
+	if cfg.HealthClient == nil {
+		cfg.HealthClient = http.DefaultClient
+	}
+
+	p := &EndpointPool{cfg: cfg}
+	for _, raw := range cfg.Endpoints {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("httpz: invalid endpoint %q: %w", raw, err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("httpz: invalid endpoint %q: scheme and host are required", raw)
+		}
+		p.endpoints = append(p.endpoints, &endpoint{url: u, raw: raw})
+	}
+
+	if cfg.Strategy == ConsistentHash {
+		for i, e := range p.endpoints {
+			for r := 0; r < cfg.Replicas; r++ {
+				p.ring = append(p.ring, ringNode{
+					hash:     crc32.ChecksumIEEE([]byte(e.raw + "#" + strconv.Itoa(r))),
+					endpoint: i,
+				})
+			}
+		}
+		sort.Slice(p.ring, func(i, j int) bool { return p.ring[i].hash < p.ring[j].hash })
+	}
+
+	if cfg.HealthCheckPath != "" {
+		ctx, cancel := context.WithCancel(context.Background())
+		p.cancel = cancel
+		p.wg.Add(1)
+		go p.healthCheck(ctx)
+	}
+
+	return p, nil
+}
+
+// WithEndpointPool balances the requests of the client over the endpoints of the pool.
+// The scheme and host of the request URLs are replaced by the picked endpoint, and the endpoint path is
+// prefixed to the request path.
+func WithEndpointPool(pool *EndpointPool) Option {
+	return func(c *Client) { c.pool = pool }
+}
+
+// Close stops the health probes.
+func (p *EndpointPool) Close() {
+	p.mu.Lock()
+	cancel := p.cancel
+	p.cancel = nil
+	p.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		p.wg.Wait()
+	}
+}
+
+// Stats returns the states of the endpoints.
+func (p *EndpointPool) Stats() []EndpointStats {
+	now := time.Now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := make([]EndpointStats, len(p.endpoints))
+	for i, e := range p.endpoints {
+		stats[i] = EndpointStats{
+			URL:      e.raw,
+			InFlight: atomic.LoadInt64(&e.inflight),
+			Failures: e.failures,
+			Ejected:  now.Before(e.ejectedUntil),
+			Healthy:  !e.unhealthy,
+		}
+	}
+	return stats
+}
+
+// withTriedEndpoints returns a ctx recording the failed endpoints of the attempts of a request.
+func withTriedEndpoints(ctx context.Context) context.Context {
+	return context.WithValue(ctx, triedEndpointsKey{}, &triedEndpoints{})
+}
+
+// do sends the attempt to an endpoint picked for the request.
+func (p *EndpointPool) do(ctx context.Context, hc *http.Client, req *http.Request) (*http.Response, error) {
+	tried, _ := ctx.Value(triedEndpointsKey{}).(*triedEndpoints)
+
+	e, err := p.pick(req, tried)
+	if err != nil {
+		return nil, err
+	}
+
+	out := req.WithContext(ctx)
+	out.URL = e.resolve(req.URL)
+	out.Host = ""
+
+	atomic.AddInt64(&e.inflight, 1)
+	var once sync.Once
+	release := func() {
+		once.Do(func() { atomic.AddInt64(&e.inflight, -1) })
+	}
+
+	resp, err := hc.Do(out)
+	if err != nil && req.Context().Err() != nil {
+		// canceled by caller, neither success nor failure
+		return bindCancel(resp, err, release)
+	}
+
+	failed := p.cfg.IsFailure(resp, err)
+	p.record(e, failed)
+	if failed && tried != nil {
+		tried.failed = append(tried.failed, e)
+	}
+
+	return bindCancel(resp, err, release)
+}
+
+// pick picks an available endpoint by the strategy, the tried endpoints are avoided if possible.
+func (p *EndpointPool) pick(req *http.Request, tried *triedEndpoints) (*endpoint, error) {
+	now := time.Now()
+
+	p.mu.Lock()
+	available := make([]bool, len(p.endpoints))
+	var n, untried int
+	for i, e := range p.endpoints {
+		if e.unhealthy || now.Before(e.ejectedUntil) {
+			continue
+		}
+		available[i] = true
+		n++
+		if !tried.has(e) {
+			untried++
+		}
+	}
+	p.mu.Unlock()
+
+	if n == 0 {
+		return nil, ErrNoEndpoint
+	}
+
+	if untried > 0 {
+		for i, e := range p.endpoints {
+			if available[i] && tried.has(e) {
+				available[i] = false
+			}
+		}
+	}
+
+	switch p.cfg.Strategy {
+	case LeastInFlight:
+		return p.pickLeastInFlight(available), nil
+	case ConsistentHash:
+		return p.pickHash(p.cfg.KeyFunc(req), available), nil
+	default:
+		return p.pickRoundRobin(available), nil
+	}
+}
+
+func (p *EndpointPool) pickRoundRobin(available []bool) *endpoint {
+	start := int(atomic.AddUint64(&p.next, 1) % uint64(len(p.endpoints)))
+	for i := 0; i < len(p.endpoints); i++ {
+		idx := (start + i) % len(p.endpoints)
+		if available[idx] {
+			return p.endpoints[idx]
+		}
+	}
+	return nil
+}
+
+func (p *EndpointPool) pickLeastInFlight(available []bool) *endpoint {
+	// start from a rotating index, so the ties are spread over the endpoints
+	start := int(atomic.AddUint64(&p.next, 1) % uint64(len(p.endpoints)))
+
+	var picked *endpoint
+	var least int64
+	for i := 0; i < len(p.endpoints); i++ {
+		idx := (start + i) % len(p.endpoints)
+		if !available[idx] {
+			continue
+		}
+
+		e := p.endpoints[idx]
+		if inflight := atomic.LoadInt64(&e.inflight); picked == nil || inflight < least {
+			picked, least = e, inflight
+		}
+	}
+	return picked
+}
+
+func (p *EndpointPool) pickHash(key string, available []bool) *endpoint {
+	hash := crc32.ChecksumIEEE([]byte(key))
+	start := sort.Search(len(p.ring), func(i int) bool { return p.ring[i].hash >= hash })
+
+	// walk the ring clockwise to the first available endpoint
+	for i := 0; i < len(p.ring); i++ {
+		node := p.ring[(start+i)%len(p.ring)]
+		if available[node.endpoint] {
+			return p.endpoints[node.endpoint]
+		}
+	}
+	return nil
+}
+
+// record records the result of an attempt, the endpoint is ejected after consecutive failures.
+func (p *EndpointPool) record(e *endpoint, failed bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !failed {
+		e.failures = 0
+		return
+	}
+
+	e.failures++
+	if e.failures >= p.cfg.FailureThreshold {
+		e.failures = 0
+		e.ejectedUntil = time.Now().Add(p.cfg.EjectDuration)
+	}
+}
+
+func (p *EndpointPool) healthCheck(ctx context.Context) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.cfg.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		p.probeAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probeAll probes the endpoints concurrently, a healthy endpoint is reinstated even if it is ejected.
+func (p *EndpointPool) probeAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, e := range p.endpoints {
+		wg.Add(1)
+		go func(e *endpoint) {
+			defer wg.Done()
+
+			healthy := p.probe(ctx, e)
+			if ctx.Err() != nil {
+				return
+			}
+
+			p.mu.Lock()
+			e.unhealthy = !healthy
+			if healthy {
+				e.failures = 0
+				e.ejectedUntil = time.Time{}
+			}
+			p.mu.Unlock()
+		}(e)
+	}
+	wg.Wait()
+}
+
+func (p *EndpointPool) probe(ctx context.Context, e *endpoint) bool {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.HealthCheckTimeout)
+	defer cancel()
+
+	target := e.resolve(&url.URL{Path: p.cfg.HealthCheckPath})
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return false
+	}
+
+	resp, err := p.cfg.HealthClient.Do(req)
+	healthy := p.cfg.IsHealthy(resp, err)
+	if err == nil {
+		_ = resp.Body.Close()
+	}
+	return healthy
+}
+
+// resolve returns the URL of u on the endpoint.
+func (e *endpoint) resolve(u *url.URL) *url.URL {
+	out := *u
+	out.Scheme = e.url.Scheme
+	out.Host = e.url.Host
+	out.User = e.url.User
+
+	if prefix := strings.TrimSuffix(e.url.Path, "/"); prefix != "" {
+		out.Path = prefix + "/" + strings.TrimPrefix(u.Path, "/")
+		if u.RawPath != "" {
+			out.RawPath = strings.TrimSuffix(e.url.EscapedPath(), "/") + "/" + strings.TrimPrefix(u.RawPath, "/")
+		}
+	}
+	return &out
+}
+
+func (t *triedEndpoints) has(e *endpoint) bool {
+	if t == nil {
+		return false
+	}
+	for _, failed := range t.failed {
+		if failed == e {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,253 @@
+package httpz
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type poolServer struct {
+	*httptest.Server
+	name     string
+	requests int32
+	status   int32
+	healthy  int32
+}
+
+func newPoolServer(t *testing.T, name string) *poolServer {
+	s := &poolServer{name: name, status: http.StatusOK, healthy: 1}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			if atomic.LoadInt32(&s.healthy) == 0 {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+
+		atomic.AddInt32(&s.requests, 1)
+		w.WriteHeader(int(atomic.LoadInt32(&s.status)))
+		_, _ = w.Write([]byte(s.name + r.URL.Path))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func poolGet(t *testing.T, c *Client, path string) (string, error) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, "http://service"+path, nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return string(body), newHTTPError(resp, nil)
+	}
+	return string(body), nil
+}
+
+func TestEndpointPool_RoundRobin(t *testing.T) {
+	a, b, c := newPoolServer(t, "a"), newPoolServer(t, "b"), newPoolServer(t, "c")
+	pool, err := NewEndpointPool(EndpointPoolConfig{Endpoints: []string{a.URL, b.URL, c.URL + "/api/"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(WithEndpointPool(pool))
+
+	for i := 0; i < 6; i++ {
+		body, err := poolGet(t, client, "/x")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if body != "a/x" && body != "b/x" && body != "c/api/x" {
+			t.Errorf("unexpected body %q", body)
+		}
+	}
+
+	for _, s := range []*poolServer{a, b, c} {
+		if n := atomic.LoadInt32(&s.requests); n != 2 {
+			t.Errorf("expected 2 requests of %s, got %d", s.name, n)
+		}
+	}
+}
+
+func TestEndpointPool_RetryAndEject(t *testing.T) {
+	a, b := newPoolServer(t, "a"), newPoolServer(t, "b")
+	atomic.StoreInt32(&a.status, http.StatusServiceUnavailable)
+
+	pool, err := NewEndpointPool(EndpointPoolConfig{Endpoints: []string{a.URL, b.URL}, FailureThreshold: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(WithEndpointPool(pool), WithRetryPolicy(RetryPolicy{MaxRetries: 1}))
+
+	for i := 0; i < 6; i++ {
+		body, err := poolGet(t, client, "/x")
+		if err != nil || body != "b/x" {
+			t.Fatalf("expected the retry to reach b, got %q %v", body, err)
+		}
+	}
+
+	if n := atomic.LoadInt32(&a.requests); n != 2 {
+		t.Errorf("expected a to be ejected after 2 failures, got %d requests", n)
+	}
+
+	stats := pool.Stats()
+	if !stats[0].Ejected || stats[1].Ejected {
+		t.Errorf("expected only a to be ejected, got %+v", stats)
+	}
+
+	atomic.StoreInt32(&b.status, http.StatusServiceUnavailable)
+	for i := 0; i < 2; i++ {
+		_, _ = poolGet(t, client, "/x")
+	}
+	if _, err = poolGet(t, client, "/x"); !errors.Is(err, ErrNoEndpoint) {
+		t.Errorf("expected ErrNoEndpoint, got %v", err)
+	}
+}
+
+func TestEndpointPool_ConsistentHash(t *testing.T) {
+	a, b, c := newPoolServer(t, "a"), newPoolServer(t, "b"), newPoolServer(t, "c")
+	pool, err := NewEndpointPool(EndpointPoolConfig{
+		Endpoints:        []string{a.URL, b.URL, c.URL},
+		Strategy:         ConsistentHash,
+		FailureThreshold: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(WithEndpointPool(pool))
+
+	first, _ := poolGet(t, client, "/users/42")
+	for i := 0; i < 5; i++ {
+		if body, _ := poolGet(t, client, "/users/42"); body != first {
+			t.Fatalf("expected the same endpoint %q, got %q", first, body)
+		}
+	}
+
+	// the key moves to another endpoint once its endpoint is ejected
+	picked := map[string]*poolServer{"a": a, "b": b, "c": c}[first[:1]]
+	atomic.StoreInt32(&picked.status, http.StatusServiceUnavailable)
+	_, _ = poolGet(t, client, "/users/42")
+
+	moved, err := poolGet(t, client, "/users/42")
+	if err != nil || moved[:1] == first[:1] {
+		t.Errorf("expected the key to move from %q, got %q %v", first, moved, err)
+	}
+}
+
+func TestEndpointPool_LeastInFlight(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Name", "slow")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+	fast := newPoolServer(t, "fast")
+
+	pool, err := NewEndpointPool(EndpointPoolConfig{Endpoints: []string{slow.URL, fast.URL}, Strategy: LeastInFlight})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(WithEndpointPool(pool))
+
+	// find the slow endpoint, its response body stays open
+	var held *http.Response
+	for held == nil {
+		req, _ := http.NewRequest(http.MethodGet, "http://service/x", nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Header.Get("X-Name") == "slow" {
+			held = resp
+			break
+		}
+		_ = resp.Body.Close()
+	}
+
+	for i := 0; i < 4; i++ {
+		if body, err := poolGet(t, client, "/x"); err != nil || body != "fast/x" {
+			t.Fatalf("expected the fast endpoint, got %q %v", body, err)
+		}
+	}
+
+	if n := pool.Stats()[0].InFlight; n != 1 {
+		t.Errorf("expected 1 in-flight request of the slow endpoint, got %d", n)
+	}
+	_ = held.Body.Close()
+	if n := pool.Stats()[0].InFlight; n != 0 {
+		t.Errorf("expected no in-flight request after the body is closed, got %d", n)
+	}
+}
+
+func TestEndpointPool_HealthCheck(t *testing.T) {
+	a, b := newPoolServer(t, "a"), newPoolServer(t, "b")
+	atomic.StoreInt32(&a.healthy, 0)
+
+	pool, err := NewEndpointPool(EndpointPoolConfig{
+		Endpoints:           []string{a.URL, b.URL},
+		HealthCheckPath:     "/health",
+		HealthCheckInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	waitStats := func(healthy bool) {
+		deadline := time.Now().Add(2 * time.Second)
+		for pool.Stats()[0].Healthy != healthy {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected a healthy %v", healthy)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	waitStats(false)
+	client := NewClient(WithEndpointPool(pool))
+	for i := 0; i < 4; i++ {
+		if body, _ := poolGet(t, client, "/x"); body != "b/x" {
+			t.Fatalf("expected the healthy endpoint, got %q", body)
+		}
+	}
+
+	atomic.StoreInt32(&a.healthy, 1)
+	waitStats(true)
+}
+
+func TestEndpointPool_Config(t *testing.T) {
+	for _, endpoints := range [][]string{nil, {"localhost:8080"}, {"http://%zz"}} {
+		if _, err := NewEndpointPool(EndpointPoolConfig{Endpoints: endpoints}); err == nil {
+			t.Errorf("expected error of endpoints %v", endpoints)
+		}
+	}
+
+	pool, _ := NewEndpointPool(EndpointPoolConfig{Endpoints: []string{"https://u:p@a.com:8443/api"}})
+	u, _ := url.Parse("http://service/v1/items?page=2")
+	if got := pool.endpoints[0].resolve(u).String(); got != "https://u:p@a.com:8443/api/v1/items?page=2" {
+		t.Errorf("unexpected resolved url %q", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://service/x", nil)
+	if _, err := NewClient(WithEndpointPool(pool)).Do(req); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if stats := pool.Stats()[0]; stats.Failures != 0 || stats.InFlight != 0 {
+		t.Errorf("expected a canceled request to be ignored, got %+v", stats)
+	}
+}
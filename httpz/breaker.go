@@ -0,0 +1,287 @@
+package httpz
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerCooldown         = 30 * time.Second
+)
+
+// ErrCircuitOpen reports that the request was rejected by an open circuit breaker.
+var ErrCircuitOpen = errors.New("httpz: circuit breaker is open")
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed requests flow normally.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen requests fail fast until the cooldown elapses.
+	BreakerOpen
+	// BreakerHalfOpen a limited number of probe requests are allowed to test the upstream.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitOpenError is returned when the circuit breaker of the request key rejects the request.
+type CircuitOpenError struct {
+	// Key the circuit breaker key of the request
+	Key string
+	// State the state of the circuit breaker, BreakerOpen or BreakerHalfOpen
+	State BreakerState
+	// RetryAfter the remaining cooldown before probe requests are allowed, 0 in half-open state
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return ErrCircuitOpen.Error() + ": key " + e.Key + ", state " + e.State.String()
+}
+
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// BreakerConfig configures a CircuitBreaker.
+type BreakerConfig struct {
+	// FailureThreshold consecutive failures to open the breaker. Default is 5
+	FailureThreshold int
+	// Cooldown duration of the open state before moving to half-open. Default is 30s
+	Cooldown time.Duration
+	// HalfOpenProbes maximum concurrent probe requests in half-open state. Default is 1
+	HalfOpenProbes int
+	// SuccessThreshold successful probes to close the breaker from half-open state. Default is 1
+	SuccessThreshold int
+	// KeyFunc returns the breaker key of the request. Default is the request host
+	KeyFunc func(req *http.Request) string
+	// IsFailure failure judgment function. Default is DefaultFailureFunc
+	IsFailure RetryableFunc
+	// OnStateChange is called when the breaker of a key changes its state
+	OnStateChange func(key string, from, to BreakerState)
+}
+
+// CircuitBreaker tracks failures per key and rejects requests while the breaker of the key is open.
+type CircuitBreaker struct {
+	cfg      BreakerConfig
+	mu       sync.Mutex
+	breakers map[string]*breaker
+}
+
+type breaker struct {
+	state      BreakerState
+	generation uint64
+	failures   int
+	successes  int
+	probes     int
+	openedAt   time.Time
+}
+
+// NewCircuitBreaker creates a new CircuitBreaker.
+func NewCircuitBreaker(cfg BreakerConfig) *CircuitBreaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = defaultBreakerFailureThreshold
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = defaultBreakerCooldown
+	}
+	if cfg.HalfOpenProbes <= 0 {
+		cfg.HalfOpenProbes = 1
+	}
+	if cfg.SuccessThreshold <= 0 {
+		cfg.SuccessThreshold = 1
+	}
+	if cfg.KeyFunc == nil {
+		cfg.KeyFunc = hostKey
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = DefaultFailureFunc
+	}
+
+	return &CircuitBreaker{
+		cfg:      cfg,
+		breakers: make(map[string]*breaker),
+	}
+}
+
+// Middleware returns the middleware which fails fast with *CircuitOpenError while the breaker is open.
+func (cb *CircuitBreaker) Middleware() Middleware {
+	return func(next DoFunc) DoFunc {
+		return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			key := cb.cfg.KeyFunc(req)
+			generation, err := cb.allow(key)
+			if err != nil {
+				return nil, err
+			}
+
+			resp, err := next(ctx, req)
+			if err != nil && ctx.Err() != nil {
+				// canceled by caller, neither success nor failure
+				cb.release(key, generation)
+				return resp, err
+			}
+
+			cb.record(key, generation, !cb.cfg.IsFailure(resp, err))
+			return resp, err
+		}
+	}
+}
+
+// State returns the state of the breaker of the key.
+func (cb *CircuitBreaker) State(key string) BreakerState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	b, ok := cb.breakers[key]
+	if !ok {
+		return BreakerClosed
+	}
+
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= cb.cfg.Cooldown {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// Reset resets the breaker of the key to closed state.
+func (cb *CircuitBreaker) Reset(key string) {
+	cb.mu.Lock()
+	b, ok := cb.breakers[key]
+	if ok {
+		from := b.state
+		delete(cb.breakers, key)
+		cb.mu.Unlock()
+		if from != BreakerClosed {
+			cb.notify(key, from, BreakerClosed)
+		}
+		return
+	}
+	cb.mu.Unlock()
+}
+
+func (cb *CircuitBreaker) allow(key string) (uint64, error) {
+	cb.mu.Lock()
+
+	b, ok := cb.breakers[key]
+	if !ok {
+		b = &breaker{}
+		cb.breakers[key] = b
+	}
+
+	var halfOpened bool
+	if b.state == BreakerOpen {
+		remain := cb.cfg.Cooldown - time.Since(b.openedAt)
+		if remain > 0 {
+			cb.mu.Unlock()
+			return 0, &CircuitOpenError{Key: key, State: BreakerOpen, RetryAfter: remain}
+		}
+		b.setState(BreakerHalfOpen)
+		halfOpened = true
+	}
+
+	if b.state == BreakerHalfOpen {
+		if b.probes >= cb.cfg.HalfOpenProbes {
+			cb.mu.Unlock()
+			return 0, &CircuitOpenError{Key: key, State: BreakerHalfOpen}
+		}
+		b.probes++
+	}
+
+	generation := b.generation
+	cb.mu.Unlock()
+
+	if halfOpened {
+		cb.notify(key, BreakerOpen, BreakerHalfOpen)
+	}
+	return generation, nil
+}
+
+func (cb *CircuitBreaker) record(key string, generation uint64, success bool) {
+	cb.mu.Lock()
+
+	b, ok := cb.breakers[key]
+	if !ok || b.generation != generation {
+		// result of a stale state
+		cb.mu.Unlock()
+		return
+	}
+
+	from := b.state
+	switch b.state {
+	case BreakerClosed:
+		if success {
+			b.failures = 0
+		} else {
+			b.failures++
+			if b.failures >= cb.cfg.FailureThreshold {
+				b.open()
+			}
+		}
+	case BreakerHalfOpen:
+		b.probes--
+		if success {
+			b.successes++
+			if b.successes >= cb.cfg.SuccessThreshold {
+				b.setState(BreakerClosed)
+			}
+		} else {
+			b.open()
+		}
+	}
+
+	to := b.state
+	cb.mu.Unlock()
+
+	if from != to {
+		cb.notify(key, from, to)
+	}
+}
+
+func (cb *CircuitBreaker) release(key string, generation uint64) {
+	cb.mu.Lock()
+	b, ok := cb.breakers[key]
+	if ok && b.generation == generation && b.state == BreakerHalfOpen {
+		b.probes--
+	}
+	cb.mu.Unlock()
+}
+
+func (cb *CircuitBreaker) notify(key string, from, to BreakerState) {
+	if cb.cfg.OnStateChange != nil {
+		cb.cfg.OnStateChange(key, from, to)
+	}
+}
+
+func (b *breaker) open() {
+	b.setState(BreakerOpen)
+	b.openedAt = time.Now()
+}
+
+// setState moves the breaker to the state and starts a new generation.
+func (b *breaker) setState(state BreakerState) {
+	b.state = state
+	b.generation++
+	b.failures = 0
+	b.successes = 0
+	b.probes = 0
+}
+
+func hostKey(req *http.Request) string {
+	return req.URL.Host
+}
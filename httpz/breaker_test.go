@@ -0,0 +1,196 @@
+package httpz
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var fail int32 = 1
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		if atomic.LoadInt32(&fail) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var transitions []string
+	cb := NewCircuitBreaker(BreakerConfig{
+		FailureThreshold: 3,
+		Cooldown:         50 * time.Millisecond,
+		OnStateChange: func(key string, from, to BreakerState) {
+			transitions = append(transitions, from.String()+"->"+to.String())
+		},
+	})
+	client := NewClient(WithMiddleware(cb.Middleware()))
+	u, _ := url.Parse(server.URL)
+
+	do := func() error {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		resp, err := client.Do(req)
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+		return err
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := do(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if cb.State(u.Host) != BreakerOpen {
+		t.Fatalf("expected breaker to be open, got %s", cb.State(u.Host))
+	}
+
+	err := do()
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) || !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected CircuitOpenError, got %v", err)
+	}
+	if openErr.Key != u.Host || openErr.State != BreakerOpen || openErr.RetryAfter <= 0 {
+		t.Errorf("unexpected error fields: %+v", openErr)
+	}
+	if n := atomic.LoadInt32(&attempts); n != 3 {
+		t.Errorf("expected 3 attempts, got %d", n)
+	}
+
+	// probe fails, breaker opens again
+	time.Sleep(60 * time.Millisecond)
+	if cb.State(u.Host) != BreakerHalfOpen {
+		t.Fatalf("expected breaker to be half-open, got %s", cb.State(u.Host))
+	}
+	if err := do(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cb.State(u.Host) != BreakerOpen {
+		t.Fatalf("expected breaker to be open, got %s", cb.State(u.Host))
+	}
+
+	// probe succeeds, breaker closes
+	atomic.StoreInt32(&fail, 0)
+	time.Sleep(60 * time.Millisecond)
+	if err := do(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cb.State(u.Host) != BreakerClosed {
+		t.Fatalf("expected breaker to be closed, got %s", cb.State(u.Host))
+	}
+
+	expected := []string{"closed->open", "open->half-open", "half-open->open", "open->half-open", "half-open->closed"}
+	if len(transitions) != len(expected) {
+		t.Fatalf("expected transitions %v, got %v", expected, transitions)
+	}
+	for i := range expected {
+		if transitions[i] != expected[i] {
+			t.Errorf("expected transitions %v, got %v", expected, transitions)
+			break
+		}
+	}
+}
+
+func TestCircuitBreaker_RateLimited(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	cb := NewCircuitBreaker(BreakerConfig{FailureThreshold: 2})
+	client := NewClient(WithMiddleware(cb.Middleware()))
+	u, _ := url.Parse(server.URL)
+
+	for i := 0; i < 5; i++ {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	if cb.State(u.Host) != BreakerClosed {
+		t.Errorf("expected 429 responses not to open the breaker, got %s", cb.State(u.Host))
+	}
+}
+
+func TestCircuitBreaker_HalfOpenProbes(t *testing.T) {
+	cb := NewCircuitBreaker(BreakerConfig{
+		FailureThreshold: 1,
+		Cooldown:         10 * time.Millisecond,
+		HalfOpenProbes:   2,
+		SuccessThreshold: 2,
+	})
+
+	gen, err := cb.allow("k")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cb.record("k", gen, false)
+
+	time.Sleep(20 * time.Millisecond)
+	gen1, err1 := cb.allow("k")
+	gen2, err2 := cb.allow("k")
+	_, err3 := cb.allow("k")
+	if err1 != nil || err2 != nil {
+		t.Fatalf("expected 2 probes to be allowed, got %v %v", err1, err2)
+	}
+	var openErr *CircuitOpenError
+	if !errors.As(err3, &openErr) || openErr.State != BreakerHalfOpen {
+		t.Fatalf("expected third probe to be rejected in half-open state, got %v", err3)
+	}
+
+	cb.record("k", gen1, true)
+	if cb.State("k") != BreakerHalfOpen {
+		t.Fatalf("expected breaker to stay half-open, got %s", cb.State("k"))
+	}
+	cb.record("k", gen2, true)
+	if cb.State("k") != BreakerClosed {
+		t.Fatalf("expected breaker to be closed, got %s", cb.State("k"))
+	}
+
+	// stale result is ignored
+	cb.record("k", gen1, false)
+	if cb.State("k") != BreakerClosed {
+		t.Fatalf("expected stale result to be ignored, got %s", cb.State("k"))
+	}
+}
+
+func TestCircuitBreaker_KeyFunc(t *testing.T) {
+	cb := NewCircuitBreaker(BreakerConfig{
+		FailureThreshold: 1,
+		KeyFunc: func(req *http.Request) string {
+			return req.URL.Path
+		},
+	})
+
+	do := cb.Middleware()(func(_ context.Context, req *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})
+
+	reqA, _ := http.NewRequest(http.MethodGet, "http://example.com/a", nil)
+	reqB, _ := http.NewRequest(http.MethodGet, "http://example.com/b", nil)
+
+	_, _ = do(reqA.Context(), reqA)
+	if _, err := do(reqA.Context(), reqA); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected breaker of /a to be open, got %v", err)
+	}
+	if _, err := do(reqB.Context(), reqB); errors.Is(err, ErrCircuitOpen) {
+		t.Error("expected breaker of /b to be closed")
+	}
+
+	cb.Reset("/a")
+	if cb.State("/a") != BreakerClosed {
+		t.Errorf("expected breaker to be reset, got %s", cb.State("/a"))
+	}
+}
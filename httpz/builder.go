@@ -0,0 +1,296 @@
+package httpz
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// RequestBuilder builds and sends a request through the Client it is bound to.
+// The base URL and default headers of the Client are applied to the request.
+// Errors of the building methods are returned when the request is built.
+type RequestBuilder struct {
+	client     *Client
+	ctx        context.Context
+	method     string
+	path       string
+	pathParams map[string]string
+	query      url.Values
+	header     http.Header
+	body       any
+	codec      Codec
+	policy     *RetryPolicy
+	timeout    time.Duration
+	err        error
+}
+
+// WithBaseURL sets the base URL of the requests built by Client.Builder.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) { c.baseURL = baseURL }
+}
+
+// WithHeader sets a default header of the requests built by Client.Builder.
+func WithHeader(key, value string) Option {
+	return func(c *Client) {
+		if c.header == nil {
+			c.header = make(http.Header)
+		}
+		c.header.Set(key, value)
+	}
+}
+
+// Builder creates a RequestBuilder of the method and path.
+// path is joined to the base URL unless it is an absolute URL, it may contain {name} placeholders
+// which are replaced by the escaped path params.
+func (c *Client) Builder(method, path string) *RequestBuilder {
+	return &RequestBuilder{
+		client: c,
+		ctx:    context.Background(),
+		method: method,
+		path:   path,
+		query:  make(url.Values),
+		header: make(http.Header),
+		codec:  JSONCodec{},
+	}
+}
+
+// Context sets the context of the request.
+func (b *RequestBuilder) Context(ctx context.Context) *RequestBuilder {
+	b.ctx = ctx
+	return b
+}
+
+// PathParam sets the value of the {key} placeholder of the path.
+func (b *RequestBuilder) PathParam(key, value string) *RequestBuilder {
+	if b.pathParams == nil {
+		b.pathParams = make(map[string]string)
+	}
+	b.pathParams[key] = value
+	return b
+}
+
+// PathParams sets the values of the placeholders of the path.
+func (b *RequestBuilder) PathParams(params map[string]string) *RequestBuilder {
+	for k, v := range params {
+		b.PathParam(k, v)
+	}
+	return b
+}
+
+// Query adds a query parameter.
+func (b *RequestBuilder) Query(key, value string) *RequestBuilder {
+	b.query.Add(key, value)
+	return b
+}
+
+// Queries adds query parameters from v, it supports the types FormCodec can marshal,
+// e.g. mapz.Body, map[string]string, url.Values and structs.
+func (b *RequestBuilder) Queries(v any) *RequestBuilder {
+	data, err := FormCodec{}.Marshal(v)
+	if err != nil {
+		b.setErr(fmt.Errorf("encode query failed: %w", err))
+		return b
+	}
+
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		b.setErr(fmt.Errorf("encode query failed: %w", err))
+		return b
+	}
+
+	for k, vs := range values {
+		b.query[k] = append(b.query[k], vs...)
+	}
+	return b
+}
+
+// Header sets a header, it overrides the default header of the Client.
+func (b *RequestBuilder) Header(key, value string) *RequestBuilder {
+	b.header.Set(key, value)
+	return b
+}
+
+// Headers sets the headers, they override the default headers of the Client.
+func (b *RequestBuilder) Headers(headers map[string]string) *RequestBuilder {
+	for k, v := range headers {
+		b.header.Set(k, v)
+	}
+	return b
+}
+
+// Body sets the body, it supports string, []byte, *Multipart, io.Reader, and other types serialized through codec.
+func (b *RequestBuilder) Body(body any) *RequestBuilder {
+	b.body = body
+	return b
+}
+
+// Codec sets the codec of the request body and response. Default is JSONCodec.
+func (b *RequestBuilder) Codec(codec Codec) *RequestBuilder {
+	b.codec = codec
+	return b
+}
+
+// RetryPolicy overrides the retry policy of the Client, like Client.DoWithRetry.
+func (b *RequestBuilder) RetryPolicy(policy RetryPolicy) *RequestBuilder {
+	b.policy = &policy
+	return b
+}
+
+// NoRetry sends the request without any retry attempts.
+func (b *RequestBuilder) NoRetry() *RequestBuilder {
+	return b.RetryPolicy(RetryPolicy{})
+}
+
+// Timeout sets the timeout of the whole request, including retries and reading the response body.
+func (b *RequestBuilder) Timeout(timeout time.Duration) *RequestBuilder {
+	b.timeout = timeout
+	return b
+}
+
+// URL returns the url of the request.
+func (b *RequestBuilder) URL() (string, error) {
+	if b.err != nil {
+		return "", b.err
+	}
+
+	path, err := expandPath(b.path, b.pathParams)
+	if err != nil {
+		return "", err
+	}
+
+	if b.client.baseURL != "" && !strings.Contains(path, "://") {
+		path = strings.TrimRight(b.client.baseURL, "/") + "/" + strings.TrimLeft(path, "/")
+	}
+
+	if len(b.query) == 0 {
+		return path, nil
+	}
+
+	u, err := url.Parse(path)
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+	for k, vs := range b.query {
+		query[k] = append(query[k], vs...)
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// Build builds the request. The timeout is not applied to the built request.
+func (b *RequestBuilder) Build() (*http.Request, error) {
+	return b.build(b.ctx)
+}
+
+// Do builds and sends the request. The timeout is canceled when the response body is closed.
+func (b *RequestBuilder) Do() (*http.Response, error) {
+	if b.timeout <= 0 {
+		req, err := b.build(b.ctx)
+		if err != nil {
+			return nil, err
+		}
+		return b.client.Do(req)
+	}
+
+	ctx, cancel := context.WithTimeout(b.ctx, b.timeout)
+	req, err := b.build(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	resp, err := b.client.Do(req)
+	return bindCancel(resp, err, cancel)
+}
+
+func (b *RequestBuilder) build(ctx context.Context) (*http.Request, error) {
+	u, err := b.URL()
+	if err != nil {
+		return nil, err
+	}
+
+	if b.policy != nil {
+		ctx = context.WithValue(ctx, clientRetryPolicyKey{}, *b.policy)
+	}
+
+	// the path template is the route of the request metrics
+	route, _, _ := strings.Cut(b.path, "?")
+	ctx = context.WithValue(ctx, routeKey{}, route)
+
+	req, err := newRequest(ctx, b.method, u, nil, b.body, b.codec)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, vs := range b.client.header {
+		if _, ok := b.header[k]; !ok {
+			req.Header[k] = append([]string(nil), vs...)
+		}
+	}
+	for k, vs := range b.header {
+		req.Header[k] = append([]string(nil), vs...)
+	}
+
+	return req, nil
+}
+
+// Into sends the request and decodes the 2xx response into out like Do[T].
+func (b *RequestBuilder) Into(out any) error {
+	resp, err := b.Do()
+	if err != nil {
+		return fmt.Errorf("send http request failed: %w", err)
+	}
+
+	return decodeResponse(resp, out, b.codec)
+}
+
+// Fetch sends the request of the builder and decodes the 2xx response into T like Do[T].
+func Fetch[T any](b *RequestBuilder) (T, error) {
+	var out T
+	err := b.Into(&out)
+	return out, err
+}
+
+func (b *RequestBuilder) setErr(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// expandPath replaces the {name} placeholders of the path by the escaped params.
+func expandPath(path string, params map[string]string) (string, error) {
+	if !strings.Contains(path, "{") {
+		return path, nil
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(path))
+	for {
+		start := strings.IndexByte(path, '{')
+		if start < 0 {
+			sb.WriteString(path)
+			return sb.String(), nil
+		}
+
+		end := strings.IndexByte(path[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("httpz: unclosed path param in %q", path)
+		}
+		end += start
+
+		name := path[start+1 : end]
+		value, ok := params[name]
+		if !ok {
+			return "", fmt.Errorf("httpz: missing path param %q", name)
+		}
+
+		sb.WriteString(path[:start])
+		sb.WriteString(url.PathEscape(value))
+		path = path[end+1:]
+	}
+}
@@ -0,0 +1,131 @@
+package httpz
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/welllog/golib/mapz"
+)
+
+func TestRequestBuilder(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		switch r.URL.EscapedPath() {
+		case "/v1/users/a%2Fb":
+			if r.URL.RawQuery != "active=true&page=2&size=10&tag=x&tag=y" {
+				t.Errorf("unexpected query: %s", r.URL.RawQuery)
+			}
+			if r.Header.Get("X-Token") != "t" || r.Header.Get("X-Trace") != "override" {
+				t.Errorf("unexpected headers: %v", r.Header)
+			}
+			_, _ = w.Write([]byte(`{"id":1,"name":"a/b"}`))
+		case "/v1/users":
+			if r.Header.Get("Content-Type") != MIMEJSON {
+				t.Errorf("unexpected Content-Type: %s", r.Header.Get("Content-Type"))
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/v1/slow":
+			time.Sleep(100 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(
+		WithBaseURL(server.URL+"/v1/"),
+		WithHeader("X-Token", "t"),
+		WithHeader("X-Trace", "default"),
+		WithRetryPolicy(RetryPolicy{MaxRetries: 2}),
+	)
+
+	type query struct {
+		Page int      `json:"page"`
+		Size int      `json:"size,omitempty"`
+		Tags []string `form:"tag"`
+	}
+
+	user, err := Fetch[typedUser](client.Builder(http.MethodGet, "/users/{id}?active=true").
+		Context(context.Background()).
+		PathParam("id", "a/b").
+		Queries(query{Page: 2, Tags: []string{"x", "y"}}).
+		Queries(mapz.Body{"size": 10}).
+		Header("X-Trace", "override"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if user.ID != 1 || user.Name != "a/b" {
+		t.Errorf("unexpected user: %+v", user)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	err = client.Builder(http.MethodPost, "users").Body(typedUser{Name: "c"}).NoRetry().Into(&user)
+	if !errors.Is(err, ErrServerStatus) || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("expected server error without retry, got %v, calls %d", err, calls)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	_, err = client.Builder(http.MethodPost, "users").Body(typedUser{Name: "c"}).
+		Header(HeaderIdempotencyKey, "k1").Do()
+	if err != nil || atomic.LoadInt32(&calls) != 3 {
+		t.Errorf("expected 3 calls with client retry policy, got %v, calls %d", err, calls)
+	}
+
+	err = client.Builder(http.MethodGet, "/slow").NoRetry().Timeout(20 * time.Millisecond).Into(&user)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestRequestBuilder_URL(t *testing.T) {
+	client := NewClient(WithBaseURL("http://example.com/api"))
+
+	tests := []struct {
+		name    string
+		builder *RequestBuilder
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "join base url",
+			builder: client.Builder(http.MethodGet, "items/{id}/{sub}").PathParams(map[string]string{"id": "1", "sub": "a b"}),
+			want:    "http://example.com/api/items/1/a%20b",
+		},
+		{
+			name:    "absolute url",
+			builder: client.Builder(http.MethodGet, "https://other.com/x").Query("q", "1"),
+			want:    "https://other.com/x?q=1",
+		},
+		{
+			name:    "missing path param",
+			builder: client.Builder(http.MethodGet, "items/{id}"),
+			wantErr: true,
+		},
+		{
+			name:    "unclosed path param",
+			builder: client.Builder(http.MethodGet, "items/{id").PathParam("id", "1"),
+			wantErr: true,
+		},
+		{
+			name:    "invalid query",
+			builder: client.Builder(http.MethodGet, "items").Queries(1),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.builder.URL()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
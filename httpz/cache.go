@@ -0,0 +1,434 @@
+package httpz
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/welllog/golib/listz"
+)
+
+const (
+	defaultCacheCapacity    = 1024
+	defaultCacheMaxBodySize = 1 << 20
+)
+
+// CacheEntry is a cached response.
+type CacheEntry struct {
+	// StatusCode status code of the response
+	StatusCode int
+	// Header header of the response
+	Header http.Header
+	// Body body of the response
+	Body []byte
+	// StoredAt the time the response was received or revalidated
+	StoredAt time.Time
+	// Vary request header values selected by the Vary response header
+	Vary map[string]string
+}
+
+// CacheStore stores cached responses. Implementations must be safe for concurrent use.
+type CacheStore interface {
+	Get(key string) (*CacheEntry, bool)
+	Set(key string, entry *CacheEntry)
+	Delete(key string)
+}
+
+// LRUCacheStore is an in-memory CacheStore that evicts the least recently used entry.
+type LRUCacheStore struct {
+	mu       sync.Mutex
+	capacity int
+	list     listz.DList[lruItem]
+	items    map[string]*listz.DNode[lruItem]
+}
+
+type lruItem struct {
+	key   string
+	entry *CacheEntry
+}
+
+// NewLRUCacheStore creates a new LRUCacheStore.
+// if capacity is less than or equal to 0, it defaults to 1024.
+func NewLRUCacheStore(capacity int) *LRUCacheStore {
+	if capacity <= 0 {
+		capacity = defaultCacheCapacity
+	}
+
+	return &LRUCacheStore{
+		capacity: capacity,
+		items:    make(map[string]*listz.DNode[lruItem]),
+	}
+}
+
+func (s *LRUCacheStore) Get(key string) (*CacheEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	node, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	s.list.MoveToFront(node)
+	return node.Value.entry, true
+}
+
+func (s *LRUCacheStore) Set(key string, entry *CacheEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if node, ok := s.items[key]; ok {
+		node.Value.entry = entry
+		s.list.MoveToFront(node)
+		return
+	}
+
+	s.items[key] = s.list.PushFront(lruItem{key: key, entry: entry})
+	if s.list.Len() > s.capacity {
+		item := s.list.Remove(s.list.Back())
+		delete(s.items, item.key)
+	}
+}
+
+func (s *LRUCacheStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if node, ok := s.items[key]; ok {
+		s.list.Remove(node)
+		delete(s.items, key)
+	}
+}
+
+// Len returns the number of cached entries.
+func (s *LRUCacheStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.list.Len()
+}
+
+// CacheConfig configures a ResponseCache.
+type CacheConfig struct {
+	// Store stores cached responses. Default is an LRUCacheStore with capacity 1024
+	Store CacheStore
+	// KeyFunc returns the cache key of the request. Default is the method and the URL
+	KeyFunc func(req *http.Request) string
+	// StaleIfError duration a stale response may be served when the upstream fails.
+	// The stale-if-error directive of the response takes precedence. Default is 0
+	StaleIfError time.Duration
+	// MaxBodySize responses with larger bodies are not cached. Default is 1MB
+	MaxBodySize int64
+}
+
+// ResponseCache caches GET and HEAD responses following the Cache-Control, Expires, ETag and Last-Modified headers.
+type ResponseCache struct {
+	cfg CacheConfig
+}
+
+// NewResponseCache creates a new ResponseCache.
+func NewResponseCache(cfg CacheConfig) *ResponseCache {
+	if cfg.Store == nil {
+		cfg.Store = NewLRUCacheStore(defaultCacheCapacity)
+	}
+	if cfg.KeyFunc == nil {
+		cfg.KeyFunc = cacheKey
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = defaultCacheMaxBodySize
+	}
+
+	return &ResponseCache{cfg: cfg}
+}
+
+// Middleware returns the caching middleware.
+// Fresh responses are served from the store, stale responses are revalidated with If-None-Match and
+// If-Modified-Since, and the cached body is served on 304.
+func (c *ResponseCache) Middleware() Middleware {
+	return func(next DoFunc) DoFunc {
+		return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			if req.Method != http.MethodGet && req.Method != http.MethodHead {
+				return next(ctx, req)
+			}
+
+			reqCC := parseCacheControl(req.Header)
+			if _, ok := reqCC["no-store"]; ok {
+				return next(ctx, req)
+			}
+
+			// the caller is doing its own revalidation
+			if req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+				return next(ctx, req)
+			}
+
+			key := c.cfg.KeyFunc(req)
+			entry, ok := c.cfg.Store.Get(key)
+			if ok && !entry.matchVary(req) {
+				entry, ok = nil, false
+			}
+
+			if !ok {
+				resp, err := next(ctx, req)
+				if err != nil {
+					return resp, err
+				}
+				return c.store(key, req, resp, reqCC), nil
+			}
+
+			now := time.Now()
+			_, noCache := reqCC["no-cache"]
+			if !noCache && entry.fresh(now) {
+				return entry.response(req, now), nil
+			}
+
+			condReq := req
+			if etag, lastModified := entry.Header.Get("ETag"), entry.Header.Get("Last-Modified"); etag != "" ||
+				lastModified != "" {
+				condReq = req.Clone(ctx)
+				if etag != "" {
+					condReq.Header.Set("If-None-Match", etag)
+				}
+				if lastModified != "" {
+					condReq.Header.Set("If-Modified-Since", lastModified)
+				}
+			}
+
+			resp, err := next(ctx, condReq)
+			if err != nil || resp.StatusCode >= 500 {
+				if c.staleIfError(entry, now) {
+					if resp != nil {
+						_ = resp.Body.Close()
+					}
+					return entry.response(req, now), nil
+				}
+				return resp, err
+			}
+
+			if resp.StatusCode == http.StatusNotModified && condReq != req {
+				_ = resp.Body.Close()
+				entry = entry.revalidate(resp.Header, now)
+				c.cfg.Store.Set(key, entry)
+				return entry.response(req, now), nil
+			}
+
+			return c.store(key, req, resp, reqCC), nil
+		}
+	}
+}
+
+// store caches the response if it is cacheable, the returned response can still be read by the caller.
+func (c *ResponseCache) store(key string, req *http.Request, resp *http.Response,
+	reqCC map[string]string) *http.Response {
+
+	if !cacheable(resp, reqCC) {
+		c.cfg.Store.Delete(key)
+		return resp
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.cfg.MaxBodySize+1))
+	if err != nil || int64(len(body)) > c.cfg.MaxBodySize {
+		// too large or broken, leave the rest of body to the caller
+		resp.Body = &multiReadCloser{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), Closer: resp.Body}
+		return resp
+	}
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	entry := &CacheEntry{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		Body:       body,
+		StoredAt:   time.Now(),
+	}
+
+	for _, name := range varyHeaders(resp.Header) {
+		if entry.Vary == nil {
+			entry.Vary = make(map[string]string)
+		}
+		entry.Vary[name] = req.Header.Get(name)
+	}
+
+	c.cfg.Store.Set(key, entry)
+	return resp
+}
+
+func (c *ResponseCache) staleIfError(entry *CacheEntry, now time.Time) bool {
+	cc := parseCacheControl(entry.Header)
+	if _, ok := cc["must-revalidate"]; ok {
+		return false
+	}
+
+	stale := c.cfg.StaleIfError
+	if v, ok := cc["stale-if-error"]; ok {
+		if sec, err := strconv.ParseInt(v, 10, 64); err == nil && sec >= 0 {
+			stale = time.Duration(sec) * time.Second
+		}
+	}
+
+	return entry.age(now) <= entry.lifetime()+stale
+}
+
+// fresh reports whether the entry can be served without revalidation.
+func (e *CacheEntry) fresh(now time.Time) bool {
+	cc := parseCacheControl(e.Header)
+	if _, ok := cc["no-cache"]; ok {
+		return false
+	}
+
+	return e.age(now) < e.lifetime()
+}
+
+// lifetime returns the freshness lifetime from max-age or Expires.
+func (e *CacheEntry) lifetime() time.Duration {
+	cc := parseCacheControl(e.Header)
+	if v, ok := cc["max-age"]; ok {
+		sec, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || sec < 0 {
+			return 0
+		}
+		return time.Duration(sec) * time.Second
+	}
+
+	if v := e.Header.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			return 0
+		}
+
+		date := e.StoredAt
+		if d, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+			date = d
+		}
+		return expires.Sub(date)
+	}
+
+	return 0
+}
+
+// age returns the current age of the entry.
+func (e *CacheEntry) age(now time.Time) time.Duration {
+	age := now.Sub(e.StoredAt)
+	if v := e.Header.Get("Age"); v != "" {
+		if sec, err := strconv.ParseInt(v, 10, 64); err == nil && sec > 0 {
+			age += time.Duration(sec) * time.Second
+		}
+	}
+	return age
+}
+
+func (e *CacheEntry) matchVary(req *http.Request) bool {
+	for name, value := range e.Vary {
+		if req.Header.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// revalidate returns a copy of the entry updated by the headers of a 304 response.
+func (e *CacheEntry) revalidate(header http.Header, now time.Time) *CacheEntry {
+	updated := *e
+	updated.Header = e.Header.Clone()
+	updated.StoredAt = now
+	updated.Header.Del("Age")
+
+	for _, name := range []string{"Cache-Control", "Date", "ETag", "Expires", "Last-Modified", "Vary"} {
+		if values, ok := header[name]; ok {
+			updated.Header[name] = values
+		}
+	}
+	return &updated
+}
+
+func (e *CacheEntry) response(req *http.Request, now time.Time) *http.Response {
+	header := e.Header.Clone()
+	header.Set("Age", strconv.FormatInt(int64(e.age(now)/time.Second), 10))
+
+	var body io.ReadCloser = http.NoBody
+	if req.Method != http.MethodHead && len(e.Body) > 0 {
+		body = io.NopCloser(bytes.NewReader(e.Body))
+	}
+
+	return &http.Response{
+		Status:        strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          body,
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+func cacheable(resp *http.Response, reqCC map[string]string) bool {
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent, http.StatusMultipleChoices,
+		http.StatusMovedPermanently, http.StatusNotFound, http.StatusGone:
+	default:
+		return false
+	}
+
+	if _, ok := reqCC["no-store"]; ok {
+		return false
+	}
+
+	cc := parseCacheControl(resp.Header)
+	if _, ok := cc["no-store"]; ok {
+		return false
+	}
+
+	for _, name := range varyHeaders(resp.Header) {
+		if name == "*" {
+			return false
+		}
+	}
+
+	_, maxAge := cc["max-age"]
+	return maxAge || resp.Header.Get("Expires") != "" || resp.Header.Get("ETag") != "" ||
+		resp.Header.Get("Last-Modified") != ""
+}
+
+// parseCacheControl parses the Cache-Control header into lower-case directives and their values.
+func parseCacheControl(header http.Header) map[string]string {
+	cc := make(map[string]string)
+	for _, line := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+
+			name, value, _ := strings.Cut(part, "=")
+			cc[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return cc
+}
+
+func varyHeaders(header http.Header) []string {
+	var names []string
+	for _, line := range header.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			name = strings.TrimSpace(name)
+			if name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+func cacheKey(req *http.Request) string {
+	return req.Method + " " + req.URL.String()
+}
+
+type multiReadCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,237 @@
+package httpz
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLRUCacheStore(t *testing.T) {
+	store := NewLRUCacheStore(2)
+	store.Set("a", &CacheEntry{StatusCode: 1})
+	store.Set("b", &CacheEntry{StatusCode: 2})
+	if _, ok := store.Get("a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+
+	store.Set("c", &CacheEntry{StatusCode: 3})
+	if _, ok := store.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	if _, ok := store.Get("a"); !ok {
+		t.Error("expected a to be kept")
+	}
+	if store.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", store.Len())
+	}
+
+	store.Delete("a")
+	if _, ok := store.Get("a"); ok {
+		t.Error("expected a to be deleted")
+	}
+}
+
+func cacheGet(t *testing.T, client *Client, url string, headers ...string) (int, string) {
+	t.Helper()
+
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestResponseCache_MaxAge(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte("v" + strconv.Itoa(int(n))))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithMiddleware(NewResponseCache(CacheConfig{}).Middleware()))
+
+	for i := 0; i < 3; i++ {
+		if code, body := cacheGet(t, client, server.URL); code != http.StatusOK || body != "v1" {
+			t.Fatalf("expected cached 'v1', got %d '%s'", code, body)
+		}
+	}
+
+	if _, body := cacheGet(t, client, server.URL, "Cache-Control", "no-store"); body != "v2" {
+		t.Errorf("expected no-store request to bypass the cache, got '%s'", body)
+	}
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Errorf("expected 2 upstream hits, got %d", n)
+	}
+}
+
+func TestResponseCache_NoStore(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = w.Write([]byte("data"))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithMiddleware(NewResponseCache(CacheConfig{}).Middleware()))
+	cacheGet(t, client, server.URL)
+	cacheGet(t, client, server.URL)
+
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Errorf("expected 2 upstream hits, got %d", n)
+	}
+}
+
+func TestResponseCache_Revalidate(t *testing.T) {
+	var hits, notModified int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", `"abc"`)
+		if r.Header.Get("If-None-Match") == `"abc"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte("payload"))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithMiddleware(NewResponseCache(CacheConfig{}).Middleware()))
+	for i := 0; i < 3; i++ {
+		if code, body := cacheGet(t, client, server.URL); code != http.StatusOK || body != "payload" {
+			t.Fatalf("expected 200 'payload', got %d '%s'", code, body)
+		}
+	}
+
+	if n := atomic.LoadInt32(&hits); n != 3 {
+		t.Errorf("expected 3 upstream hits, got %d", n)
+	}
+	if n := atomic.LoadInt32(&notModified); n != 2 {
+		t.Errorf("expected 2 revalidations, got %d", n)
+	}
+}
+
+func TestResponseCache_LastModified(t *testing.T) {
+	lastModified := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	var conditional int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", lastModified)
+		if r.Header.Get("If-Modified-Since") == lastModified {
+			atomic.AddInt32(&conditional, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte("doc"))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithMiddleware(NewResponseCache(CacheConfig{}).Middleware()))
+	cacheGet(t, client, server.URL)
+	if _, body := cacheGet(t, client, server.URL); body != "doc" {
+		t.Errorf("expected cached body 'doc', got '%s'", body)
+	}
+	if n := atomic.LoadInt32(&conditional); n != 1 {
+		t.Errorf("expected 1 conditional request, got %d", n)
+	}
+}
+
+func TestResponseCache_StaleIfError(t *testing.T) {
+	var fail int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&fail) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=0, stale-if-error=60")
+		_, _ = w.Write([]byte("stale"))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithMiddleware(NewResponseCache(CacheConfig{}).Middleware()))
+	cacheGet(t, client, server.URL)
+
+	atomic.StoreInt32(&fail, 1)
+	if code, body := cacheGet(t, client, server.URL); code != http.StatusOK || body != "stale" {
+		t.Errorf("expected stale response, got %d '%s'", code, body)
+	}
+
+	cache := NewResponseCache(CacheConfig{StaleIfError: time.Minute})
+	do := cache.Middleware()(func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})
+	cache.cfg.Store.Set("GET http://example.com", &CacheEntry{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Cache-Control": {"max-age=1"}},
+		Body:       []byte("old"),
+		StoredAt:   time.Now().Add(-10 * time.Second),
+	})
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	resp, err := do(req.Context(), req)
+	if err != nil {
+		t.Fatalf("expected stale response on error, got %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "old" || resp.Header.Get("Age") != "10" {
+		t.Errorf("expected stale body 'old' with age 10, got '%s' age %s", string(body), resp.Header.Get("Age"))
+	}
+}
+
+func TestResponseCache_Vary(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		_, _ = w.Write([]byte(r.Header.Get("Accept-Language")))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithMiddleware(NewResponseCache(CacheConfig{}).Middleware()))
+	cacheGet(t, client, server.URL, "Accept-Language", "en")
+	if _, body := cacheGet(t, client, server.URL, "Accept-Language", "en"); body != "en" {
+		t.Errorf("expected 'en', got '%s'", body)
+	}
+	if _, body := cacheGet(t, client, server.URL, "Accept-Language", "fr"); body != "fr" {
+		t.Errorf("expected 'fr', got '%s'", body)
+	}
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Errorf("expected 2 upstream hits, got %d", n)
+	}
+}
+
+func TestResponseCache_MaxBodySize(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte("0123456789"))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithMiddleware(NewResponseCache(CacheConfig{MaxBodySize: 4}).Middleware()))
+	for i := 0; i < 2; i++ {
+		if _, body := cacheGet(t, client, server.URL); body != "0123456789" {
+			t.Errorf("expected the full body, got '%s'", body)
+		}
+	}
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Errorf("expected 2 upstream hits, got %d", n)
+	}
+}
@@ -3,6 +3,7 @@ package httpz
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -14,6 +15,8 @@ import (
 
 type clientRetryPolicyKey struct{}
 
+var errBodyNotRebuildable = errors.New("httpz: request body cannot be rebuilt without GetBody")
+
 type Codec interface {
 	Marshal(v any) ([]byte, error)
 	Unmarshal(data []byte, v any) error
@@ -24,6 +27,9 @@ type Client struct {
 	retryPolicy RetryPolicy
 	middlewares []Middleware
 	doChain     DoFunc
+	baseURL     string
+	header      http.Header
+	pool        *EndpointPool
 }
 
 func NewClient(opts ...Option) *Client {
@@ -71,11 +77,45 @@ func (c *Client) DoWithoutRetry(req *http.Request) (*http.Response, error) {
 }
 
 // Request
-// body supports string, []byte, io.Reader, and other types serialized through codec
+// body supports string, []byte, *Multipart, io.Reader, and other types serialized through codec
 func (c *Client) Request(ctx context.Context, method, path string, headers map[string]string, body, out any,
 	codec Codec) (err error) {
 
-	var req *http.Request
+	req, err := newRequest(ctx, method, path, headers, body, codec)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return fmt.Errorf("send http request failed: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("http server error: status code %d", resp.StatusCode)
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read http response body failed: %w", err)
+	}
+
+	err = unmarshal(codec, resp.Header.Get("Content-Type"), b, out)
+	if err != nil {
+		return fmt.Errorf("unmarshal http response body failed: %w, body: %s", err, strz.UnsafeString(b))
+	}
+
+	return nil
+}
+
+// newRequest creates a request with the body and headers.
+// body supports string, []byte, *Multipart, io.Reader, and other types serialized through codec
+func newRequest(ctx context.Context, method, path string, headers map[string]string, body any,
+	codec Codec) (req *http.Request, err error) {
+
+	var marshaled bool
 	if body == nil {
 		req, err = http.NewRequestWithContext(ctx, method, path, nil)
 	} else {
@@ -84,95 +124,153 @@ func (c *Client) Request(ctx context.Context, method, path string, headers map[s
 			req, err = http.NewRequestWithContext(ctx, method, path, strings.NewReader(r))
 		case []byte:
 			req, err = http.NewRequestWithContext(ctx, method, path, bytes.NewBuffer(r))
+		case *Multipart:
+			req, err = r.NewRequest(ctx, method, path)
 		case io.Reader:
 			req, err = http.NewRequestWithContext(ctx, method, path, r)
 		default:
+			if codec == nil {
+				return nil, fmt.Errorf("marshal request body failed: no codec for %T", body)
+			}
+
 			var b []byte
 			b, err = codec.Marshal(body)
 			if err != nil {
-				return fmt.Errorf("marshal request body failed: %w", err)
+				return nil, fmt.Errorf("marshal request body failed: %w", err)
 			}
 
 			req, err = http.NewRequestWithContext(ctx, method, path, bytes.NewBuffer(b))
+			marshaled = true
 		}
 	}
 
 	if err != nil {
-		return fmt.Errorf("create http request failed: %w", err)
+		return nil, fmt.Errorf("create http request failed: %w", err)
 	}
 
 	for k, v := range headers {
 		req.Header.Set(k, v)
 	}
 
-	resp, err := c.Do(req)
-	if err != nil {
-		return fmt.Errorf("send http request failed: %w", err)
+	if codec != nil {
+		setCodecHeaders(req, codec, marshaled)
 	}
 
-	defer resp.Body.Close()
+	return req, nil
+}
 
-	if resp.StatusCode >= 500 {
-		return fmt.Errorf("http server error: status code %d", resp.StatusCode)
+func (c *Client) doWithRetry(ctx context.Context, req *http.Request) (*http.Response, error) {
+	policyVal := ctx.Value(clientRetryPolicyKey{})
+	retryPolicy, ok := policyVal.(RetryPolicy)
+	if !ok { // use global retry policy
+		retryPolicy = c.retryPolicy
+	} else {
+		if retryPolicy.ShouldRetry == nil {
+			retryPolicy.ShouldRetry = c.retryPolicy.ShouldRetry
+		}
+		if retryPolicy.Backoff == nil {
+			retryPolicy.Backoff = c.retryPolicy.Backoff
+		}
+		if retryPolicy.Budget == nil {
+			retryPolicy.Budget = c.retryPolicy.Budget
+		}
+		if retryPolicy.IdempotencyKey == nil {
+			retryPolicy.IdempotencyKey = c.retryPolicy.IdempotencyKey
+		}
 	}
 
-	b, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("read http response body failed: %w", err)
+	if retryPolicy.Budget != nil {
+		retryPolicy.Budget.Deposit()
 	}
 
-	err = codec.Unmarshal(b, out)
-	if err != nil {
-		return fmt.Errorf("unmarshal http response body failed: %w, body: %s", err, strz.UnsafeString(b))
+	if c.pool != nil {
+		ctx = withTriedEndpoints(ctx)
 	}
 
-	return nil
-}
+	if retryPolicy.MaxRetries > 0 && retryPolicy.IdempotencyKey != nil && !idempotentMethod(req.Method) &&
+		req.Header.Get(HeaderIdempotencyKey) == "" {
+		// the key is set to a copy of the request, so it is not reused by another request
+		header := req.Header.Clone()
+		if header == nil {
+			header = make(http.Header)
+		}
+		req = req.WithContext(req.Context())
+		req.Header = header
+		req.Header.Set(HeaderIdempotencyKey, retryPolicy.IdempotencyKey(req))
+	}
 
-func (c *Client) doWithRetry(ctx context.Context, req *http.Request) (*http.Response, error) {
-	policyVal := ctx.Value(clientRetryPolicyKey{})
-	retryPolicy, ok := policyVal.(RetryPolicy)
-	if !ok { // use global retry policy
-		retryPolicy = c.retryPolicy
-	} else if retryPolicy.ShouldRetry == nil {
-		retryPolicy.ShouldRetry = c.retryPolicy.ShouldRetry
+	if retryPolicy.TotalTimeout <= 0 {
+		return c.retry(ctx, ctx, req, &retryPolicy)
 	}
 
+	budgetCtx, cancel := context.WithTimeout(ctx, retryPolicy.TotalTimeout)
+	resp, err := c.retry(ctx, budgetCtx, req, &retryPolicy)
+	return bindCancel(resp, err, cancel)
+}
+
+// retry executes the request with retries.
+// parent is the caller's context, ctx is derived from parent and carries the deadline budget of all attempts.
+func (c *Client) retry(parent, ctx context.Context, req *http.Request, retryPolicy *RetryPolicy) (*http.Response, error) {
 	if retryPolicy.MaxRetries <= 0 {
-		return c.do(ctx, req)
+		return c.attempt(parent, ctx, req, retryPolicy, 1)
 	}
 
 	// no retry if body cannot be copied
-	if req.Body != nil && req.GetBody == nil {
-		return c.do(ctx, req)
+	if !canRebuildBody(req) {
+		return c.attempt(parent, ctx, req, retryPolicy, 1)
+	}
+
+	// no retry of non-idempotent requests without an idempotency key
+	if !retryPolicy.RetryNonIdempotent && !idempotentMethod(req.Method) && req.Header.Get(HeaderIdempotencyKey) == "" {
+		return c.attempt(parent, ctx, req, retryPolicy, 1)
 	}
 
 	var resp *http.Response
 	var err error
+	var delay time.Duration
+
+	for attempt := 1; attempt <= retryPolicy.MaxRetries+1; attempt++ {
+		if attempt > 1 {
+			delay = retryDelay(retryPolicy, attempt-1, delay, resp)
+
+			// no new attempt if the remaining budget cannot cover it
+			if !budgetCovers(ctx, delay+retryPolicy.AttemptTimeout) {
+				if err != nil {
+					err = &TimeoutError{Attempt: attempt - 1, Total: true, Err: err}
+				}
+				return resp, err
+			}
+
+			if rebuildBody(req) != nil {
+				// copy failed, cannot retry
+				break
+			}
 
-	for attempt := 0; attempt <= retryPolicy.MaxRetries; attempt++ {
-		if attempt > 0 {
-			if req.Body != nil {
-				copyBody, copyErr := req.GetBody()
-				if copyErr != nil {
-					// copy failed, cannot retry
-					break
+			// no retry if the retry budget is exhausted, it is withdrawn only for a retry which is made
+			if retryPolicy.Budget != nil && !retryPolicy.Budget.Withdraw() {
+				if req.Body != nil {
+					_ = req.Body.Close()
 				}
-				req.Body = copyBody
+				return resp, err
 			}
 
 			if resp != nil && resp.Body != nil {
 				resp.Body.Close()
 			}
 
-			backoff := retryPolicy.MinRetryDelay * time.Duration(1<<uint(attempt-1))
-
-			if backoff > 0 {
-				time.Sleep(backoff)
+			if waitErr := sleepContext(ctx, delay); waitErr != nil {
+				// the rebuilt body is never sent
+				if req.Body != nil {
+					_ = req.Body.Close()
+				}
+				if parent.Err() == nil {
+					waitErr = &TimeoutError{Attempt: attempt - 1, Total: true, Err: waitErr}
+				}
+				return nil, waitErr
 			}
 		}
 
-		resp, err = c.do(ctx, req)
+		resp, err = c.attempt(parent, ctx, req, retryPolicy, attempt)
 		if !retryPolicy.ShouldRetry(resp, err) {
 			return resp, err
 		}
@@ -181,6 +279,72 @@ func (c *Client) doWithRetry(ctx context.Context, req *http.Request) (*http.Resp
 	return resp, err
 }
 
+// attempt executes a single attempt with the per-attempt timeout of retryPolicy.
+func (c *Client) attempt(parent, ctx context.Context, req *http.Request, retryPolicy *RetryPolicy,
+	attempt int) (*http.Response, error) {
+
+	attemptCtx := ctx
+	var cancel context.CancelFunc
+	if retryPolicy.AttemptTimeout > 0 {
+		attemptCtx, cancel = context.WithTimeout(ctx, retryPolicy.AttemptTimeout)
+	}
+
+	begin := time.Now()
+	resp, err := c.do(attemptCtx, req)
+	if err != nil {
+		switch {
+		case parent.Err() != nil: // canceled by caller
+		case ctx.Err() == context.DeadlineExceeded:
+			err = &TimeoutError{Attempt: attempt, Total: true, Err: err}
+		case attemptCtx.Err() == context.DeadlineExceeded:
+			err = &TimeoutError{Attempt: attempt, Err: err}
+		}
+	}
+	notifyAttempt(ctx, attempt, resp, err, time.Since(begin))
+
+	if cancel == nil {
+		return resp, err
+	}
+
+	return bindCancel(resp, err, cancel)
+}
+
 func (c *Client) do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	if c.pool != nil {
+		return c.pool.do(ctx, c.client, req)
+	}
 	return c.client.Do(req.WithContext(ctx))
 }
+
+// canRebuildBody reports whether the request body can be rebuilt through req.GetBody.
+func canRebuildBody(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// rebuildBody replaces the request body with a new copy from req.GetBody, so the request can be sent again.
+func rebuildBody(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+
+	if req.GetBody == nil {
+		return errBodyNotRebuildable
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return err
+	}
+
+	req.Body = body
+	return nil
+}
+
+// cloneRequest returns a copy of the request with a rebuilt body, so it can be sent concurrently with req.
+func cloneRequest(ctx context.Context, req *http.Request) (*http.Request, error) {
+	clone := req.Clone(ctx)
+	if err := rebuildBody(clone); err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
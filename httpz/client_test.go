@@ -193,6 +193,7 @@ func TestClient_DoWithRetry(t *testing.T) {
 
 		client := NewClient()
 		req, _ := http.NewRequest(http.MethodPost, server.URL, bytes.NewBufferString("test body"))
+		req.Header.Set(HeaderIdempotencyKey, "k1")
 
 		resp, err := client.DoWithRetry(req, RetryPolicy{
 			MaxRetries:    2,
@@ -511,7 +512,7 @@ func TestClient_doWithRetry(t *testing.T) {
 			MinRetryDelay: 10 * time.Millisecond,
 		}))
 
-		req, _ := http.NewRequest(http.MethodPost, server.URL, bytes.NewBufferString("test"))
+		req, _ := http.NewRequest(http.MethodPut, server.URL, bytes.NewBufferString("test"))
 		req.GetBody = func() (io.ReadCloser, error) {
 			if attempts > 1 {
 				return nil, errors.New("cannot recreate body")
@@ -528,4 +529,45 @@ func TestClient_doWithRetry(t *testing.T) {
 			t.Errorf("expected 2 attempts (stops when GetBody fails), got %d", attempts)
 		}
 	})
+
+	t.Run("rebuilt body closed when wait canceled", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		client := NewClient(WithRetryPolicy(RetryPolicy{
+			MaxRetries:    3,
+			MinRetryDelay: 10 * time.Second,
+		}))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		var bodies []*closeTracker
+		req, _ := http.NewRequestWithContext(ctx, http.MethodPut, server.URL, strings.NewReader("test"))
+		req.GetBody = func() (io.ReadCloser, error) {
+			b := &closeTracker{Reader: strings.NewReader("test")}
+			bodies = append(bodies, b)
+			return b, nil
+		}
+
+		if _, err := client.Do(req); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+		if len(bodies) != 1 || !bodies[0].closed {
+			t.Errorf("expected the rebuilt body to be closed, got %d bodies", len(bodies))
+		}
+	})
+}
+
+type closeTracker struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed = true
+	return nil
 }
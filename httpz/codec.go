@@ -0,0 +1,467 @@
+package httpz
+
+import (
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/welllog/golib/mapz"
+	"github.com/welllog/golib/strz"
+)
+
+// media types of the built-in codecs
+const (
+	MIMEJSON = "application/json"
+	MIMEXML  = "application/xml"
+	MIMEForm = "application/x-www-form-urlencoded"
+	MIMEText = "text/plain"
+)
+
+// ContentTyper is implemented by codecs to set the Content-Type header of the request body they marshal,
+// and the Accept header of the request.
+type ContentTyper interface {
+	ContentType() string
+}
+
+// Accepter is implemented by codecs accepting more than one media type, the result is used as the Accept header.
+type Accepter interface {
+	Accept() string
+}
+
+// ContentTypeUnmarshaler is implemented by codecs choosing the decoder by the response Content-Type.
+type ContentTypeUnmarshaler interface {
+	UnmarshalContentType(contentType string, data []byte, v any) error
+}
+
+// JSONCodec encodes and decodes JSON.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func (JSONCodec) ContentType() string {
+	return MIMEJSON
+}
+
+// XMLCodec encodes and decodes XML.
+type XMLCodec struct{}
+
+func (XMLCodec) Marshal(v any) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+func (XMLCodec) Unmarshal(data []byte, v any) error {
+	return xml.Unmarshal(data, v)
+}
+
+func (XMLCodec) ContentType() string {
+	return MIMEXML
+}
+
+// FormCodec encodes and decodes application/x-www-form-urlencoded.
+// Marshal supports mapz.Body, map[string]any, map[string]string, url.Values, map[string][]string and structs.
+// Unmarshal supports *url.Values, *map[string][]string, *map[string]string, *mapz.Body, *map[string]any and
+// pointers to structs. Struct fields are named by the form tag, then the json tag, then the field name.
+type FormCodec struct{}
+
+func (FormCodec) Marshal(v any) ([]byte, error) {
+	switch m := v.(type) {
+	case mapz.Body:
+		return m.QueryBytes(url.QueryEscape), nil
+	case map[string]any:
+		return mapz.Body(m).QueryBytes(url.QueryEscape), nil
+	case url.Values:
+		return strz.UnsafeBytes(m.Encode()), nil
+	case map[string][]string:
+		return strz.UnsafeBytes(url.Values(m).Encode()), nil
+	case map[string]string:
+		values := make(url.Values, len(m))
+		for k, s := range m {
+			values.Set(k, s)
+		}
+		return strz.UnsafeBytes(values.Encode()), nil
+	}
+
+	values, err := structValues(v)
+	if err != nil {
+		return nil, err
+	}
+	return strz.UnsafeBytes(values.Encode()), nil
+}
+
+func (FormCodec) Unmarshal(data []byte, v any) error {
+	values, err := url.ParseQuery(strz.UnsafeString(data))
+	if err != nil {
+		return err
+	}
+
+	switch m := v.(type) {
+	case *url.Values:
+		*m = values
+	case *map[string][]string:
+		*m = values
+	case *map[string]string:
+		*m = make(map[string]string, len(values))
+		for k := range values {
+			(*m)[k] = values.Get(k)
+		}
+	case *mapz.Body:
+		*m = make(mapz.Body, len(values))
+		for k := range values {
+			(*m)[k] = values.Get(k)
+		}
+	case *map[string]any:
+		*m = make(map[string]any, len(values))
+		for k := range values {
+			(*m)[k] = values.Get(k)
+		}
+	default:
+		return setStructValues(v, values)
+	}
+
+	return nil
+}
+
+func (FormCodec) ContentType() string {
+	return MIMEForm
+}
+
+// TextCodec encodes and decodes plain text.
+// Marshal supports string, []byte, encoding.TextMarshaler, fmt.Stringer and basic types.
+// Unmarshal supports *string, *[]byte and encoding.TextUnmarshaler.
+type TextCodec struct{}
+
+func (TextCodec) Marshal(v any) ([]byte, error) {
+	switch t := v.(type) {
+	case string:
+		return []byte(t), nil
+	case []byte:
+		return t, nil
+	case encoding.TextMarshaler:
+		return t.MarshalText()
+	default:
+		return []byte(strz.ToString(v)), nil
+	}
+}
+
+func (TextCodec) Unmarshal(data []byte, v any) error {
+	switch t := v.(type) {
+	case *string:
+		*t = string(data)
+	case *[]byte:
+		*t = append((*t)[:0], data...)
+	case encoding.TextUnmarshaler:
+		return t.UnmarshalText(data)
+	default:
+		return fmt.Errorf("httpz: text codec cannot unmarshal into %T", v)
+	}
+	return nil
+}
+
+func (TextCodec) ContentType() string {
+	return MIMEText + "; charset=utf-8"
+}
+
+// NegotiatingCodec marshals request bodies with the default codec, advertises all media types of its codecs in
+// the Accept header, and picks the decoder by the response Content-Type.
+type NegotiatingCodec struct {
+	def    Codec
+	codecs map[string]Codec
+	accept string
+}
+
+// NewNegotiatingCodec creates a new NegotiatingCodec.
+// def is used to marshal request bodies and to decode responses of unknown media types.
+// codecs are registered by their ContentType, codecs without ContentType are ignored.
+func NewNegotiatingCodec(def Codec, codecs ...Codec) *NegotiatingCodec {
+	n := &NegotiatingCodec{
+		def:    def,
+		codecs: make(map[string]Codec),
+	}
+
+	var accepts []string
+	for _, c := range append([]Codec{def}, codecs...) {
+		ct, ok := c.(ContentTyper)
+		if !ok {
+			continue
+		}
+
+		mediaType := parseMediaType(ct.ContentType())
+		if _, ok := n.codecs[mediaType]; ok {
+			continue
+		}
+		n.codecs[mediaType] = c
+
+		if len(accepts) == 0 {
+			accepts = append(accepts, mediaType)
+		} else {
+			accepts = append(accepts, mediaType+";q=0.9")
+		}
+	}
+	n.accept = strings.Join(accepts, ", ")
+
+	return n
+}
+
+func (n *NegotiatingCodec) Marshal(v any) ([]byte, error) {
+	return n.def.Marshal(v)
+}
+
+func (n *NegotiatingCodec) Unmarshal(data []byte, v any) error {
+	return n.def.Unmarshal(data, v)
+}
+
+func (n *NegotiatingCodec) ContentType() string {
+	if ct, ok := n.def.(ContentTyper); ok {
+		return ct.ContentType()
+	}
+	return ""
+}
+
+func (n *NegotiatingCodec) Accept() string {
+	return n.accept
+}
+
+func (n *NegotiatingCodec) UnmarshalContentType(contentType string, data []byte, v any) error {
+	return n.lookup(contentType).Unmarshal(data, v)
+}
+
+// lookup returns the codec of the media type, structured syntax suffixes like +json and +xml are supported.
+func (n *NegotiatingCodec) lookup(contentType string) Codec {
+	mediaType := parseMediaType(contentType)
+	if mediaType == "" {
+		return n.def
+	}
+
+	if c, ok := n.codecs[mediaType]; ok {
+		return c
+	}
+
+	if i := strings.LastIndexByte(mediaType, '+'); i >= 0 {
+		if c, ok := n.codecs["application/"+mediaType[i+1:]]; ok {
+			return c
+		}
+	}
+
+	if mediaType == "text/xml" {
+		if c, ok := n.codecs[MIMEXML]; ok {
+			return c
+		}
+	}
+
+	return n.def
+}
+
+// setCodecHeaders sets the Content-Type and Accept headers by the codec if they are not set.
+func setCodecHeaders(req *http.Request, codec Codec, marshaled bool) {
+	if marshaled && req.Header.Get("Content-Type") == "" {
+		if ct, ok := codec.(ContentTyper); ok && ct.ContentType() != "" {
+			req.Header.Set("Content-Type", ct.ContentType())
+		}
+	}
+
+	if req.Header.Get("Accept") == "" {
+		if a, ok := codec.(Accepter); ok && a.Accept() != "" {
+			req.Header.Set("Accept", a.Accept())
+		} else if ct, ok := codec.(ContentTyper); ok && ct.ContentType() != "" {
+			req.Header.Set("Accept", parseMediaType(ct.ContentType()))
+		}
+	}
+}
+
+// unmarshal decodes data through the codec, ContentTypeUnmarshaler is respected.
+func unmarshal(codec Codec, contentType string, data []byte, v any) error {
+	if cu, ok := codec.(ContentTypeUnmarshaler); ok {
+		return cu.UnmarshalContentType(contentType, data, v)
+	}
+	return codec.Unmarshal(data, v)
+}
+
+func parseMediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, _, _ = strings.Cut(contentType, ";")
+		return strings.ToLower(strings.TrimSpace(mediaType))
+	}
+	return mediaType
+}
+
+// structValues encodes the exported fields of a struct into url.Values.
+func structValues(v any) (url.Values, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return url.Values{}, nil
+		}
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("httpz: cannot encode %T as form values", v)
+	}
+
+	values := make(url.Values)
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, omitEmpty := fieldName(field)
+		if name == "" {
+			continue
+		}
+
+		fv := rv.Field(i)
+		if omitEmpty && fv.IsZero() {
+			continue
+		}
+
+		for fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				break
+			}
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Pointer {
+			continue
+		}
+
+		if (fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8) || fv.Kind() == reflect.Array {
+			for j := 0; j < fv.Len(); j++ {
+				values.Add(name, strz.ToString(fv.Index(j).Interface()))
+			}
+			continue
+		}
+
+		values.Add(name, strz.ToString(fv.Interface()))
+	}
+
+	return values, nil
+}
+
+// setStructValues decodes url.Values into the exported fields of a struct of basic types.
+func setStructValues(v any, values url.Values) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("httpz: cannot decode form values into %T", v)
+	}
+
+	rv = rv.Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _ := fieldName(field)
+		vs, ok := values[name]
+		if name == "" || !ok || len(vs) == 0 {
+			continue
+		}
+
+		fv := rv.Field(i)
+		if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+			slice := reflect.MakeSlice(fv.Type(), len(vs), len(vs))
+			for j, s := range vs {
+				if err := setBasicValue(slice.Index(j), s); err != nil {
+					return fmt.Errorf("httpz: decode form field %s failed: %w", name, err)
+				}
+			}
+			fv.Set(slice)
+			continue
+		}
+
+		if err := setBasicValue(fv, vs[0]); err != nil {
+			return fmt.Errorf("httpz: decode form field %s failed: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func setBasicValue(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	if v.CanAddr() {
+		if tu, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return tu.UnmarshalText([]byte(s))
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		v.SetBytes([]byte(s))
+	default:
+		return errors.New("unsupported type " + v.Type().String())
+	}
+
+	return nil
+}
+
+// fieldName returns the form name of the struct field from the form tag, the json tag or the field name.
+func fieldName(field reflect.StructField) (string, bool) {
+	tag, ok := field.Tag.Lookup("form")
+	if !ok {
+		tag, ok = field.Tag.Lookup("json")
+	}
+	if !ok {
+		return field.Name, false
+	}
+
+	if tag == "-" {
+		return "", false
+	}
+
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	return name, strings.Contains(","+opts+",", ",omitempty,")
+}
@@ -0,0 +1,146 @@
+package httpz
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/welllog/golib/mapz"
+)
+
+type codecItem struct {
+	XMLName xml.Name `json:"-" xml:"item"`
+	Name    string   `json:"name" xml:"name"`
+	Count   int      `json:"count,omitempty" xml:"count"`
+	Tags    []string `form:"tag" json:"tags" xml:"tag"`
+	Skip    string   `form:"-" json:"skip" xml:"-"`
+}
+
+func TestJSONAndXMLCodec(t *testing.T) {
+	item := codecItem{Name: "a", Count: 2, Tags: []string{"x"}}
+	for _, codec := range []Codec{JSONCodec{}, XMLCodec{}} {
+		b, err := codec.Marshal(item)
+		if err != nil {
+			t.Fatalf("unexpected marshal error: %v", err)
+		}
+
+		var out codecItem
+		if err := codec.Unmarshal(b, &out); err != nil {
+			t.Fatalf("unexpected unmarshal error: %v", err)
+		}
+		if out.Name != "a" || out.Count != 2 || len(out.Tags) != 1 {
+			t.Errorf("unexpected result: %+v", out)
+		}
+	}
+}
+
+func TestFormCodec(t *testing.T) {
+	codec := FormCodec{}
+
+	b, _ := codec.Marshal(mapz.Body{"b": 2, "a": "x y"})
+	if string(b) != "a=x+y&b=2" {
+		t.Errorf("unexpected mapz.Body encoding: %s", string(b))
+	}
+
+	b, _ = codec.Marshal(map[string]string{"k": "v&"})
+	if string(b) != "k=v%26" {
+		t.Errorf("unexpected map encoding: %s", string(b))
+	}
+
+	b, err := codec.Marshal(&codecItem{Name: "n", Tags: []string{"t1", "t2"}, Skip: "s"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(b) != "name=n&tag=t1&tag=t2" {
+		t.Errorf("unexpected struct encoding: %s", string(b))
+	}
+
+	var item codecItem
+	if err := codec.Unmarshal([]byte("name=n&count=3&tag=a&tag=b&skip=s"), &item); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if item.Name != "n" || item.Count != 3 || len(item.Tags) != 2 || item.Skip != "" {
+		t.Errorf("unexpected struct decoding: %+v", item)
+	}
+
+	if err := codec.Unmarshal([]byte("count=x"), &item); err == nil {
+		t.Error("expected error on invalid int")
+	}
+
+	var values url.Values
+	_ = codec.Unmarshal([]byte("a=1&a=2"), &values)
+	if len(values["a"]) != 2 {
+		t.Errorf("unexpected url.Values: %v", values)
+	}
+
+	var m map[string]string
+	_ = codec.Unmarshal([]byte("a=1"), &m)
+	if m["a"] != "1" {
+		t.Errorf("unexpected map: %v", m)
+	}
+
+	if _, err := codec.Marshal(1); err == nil {
+		t.Error("expected error on unsupported type")
+	}
+}
+
+func TestTextCodec(t *testing.T) {
+	codec := TextCodec{}
+	if b, _ := codec.Marshal(12); string(b) != "12" {
+		t.Errorf("unexpected text: %s", string(b))
+	}
+
+	var s string
+	_ = codec.Unmarshal([]byte("hello"), &s)
+	if s != "hello" {
+		t.Errorf("unexpected text: %s", s)
+	}
+
+	var n int
+	if err := codec.Unmarshal([]byte("1"), &n); err == nil {
+		t.Error("expected error on unsupported type")
+	}
+}
+
+func TestNegotiatingCodec(t *testing.T) {
+	codec := NewNegotiatingCodec(JSONCodec{}, XMLCodec{}, TextCodec{}, jsonCodec{})
+	if codec.Accept() != "application/json, application/xml;q=0.9, text/plain;q=0.9" {
+		t.Errorf("unexpected Accept: %s", codec.Accept())
+	}
+
+	var item codecItem
+	if err := codec.UnmarshalContentType("application/problem+xml; charset=utf-8",
+		[]byte("<item><name>x</name></item>"), &item); err != nil || item.Name != "x" {
+		t.Errorf("expected xml decoding, got %+v %v", item, err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != MIMEJSON {
+			t.Errorf("unexpected Content-Type: %s", r.Header.Get("Content-Type"))
+		}
+		if r.Header.Get("Accept") != codec.Accept() {
+			t.Errorf("unexpected Accept: %s", r.Header.Get("Accept"))
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		var in codecItem
+		_ = JSONCodec{}.Unmarshal(body, &in)
+
+		w.Header().Set("Content-Type", "text/xml")
+		b, _ := XMLCodec{}.Marshal(in)
+		_, _ = w.Write(b)
+	}))
+	defer server.Close()
+
+	out, err := Post[codecItem](context.Background(), NewClient(), server.URL, nil, codecItem{Name: "neg"}, codec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.Name != "neg" {
+		t.Errorf("unexpected result: %+v", out)
+	}
+}
@@ -0,0 +1,274 @@
+package httpz
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultCompressMinSize     = 1 << 10
+	defaultCompressBufferSize  = 1 << 20
+	defaultMaxDecompressedSize = 32 << 20
+	encodingGzip               = "gzip"
+	encodingDeflate            = "deflate"
+	acceptEncodings            = encodingGzip + ", " + encodingDeflate
+)
+
+// ErrDecompressedTooLarge reports that the decompressed response body exceeds the limit.
+var ErrDecompressedTooLarge = errors.New("httpz: decompressed body exceeds the limit")
+
+// CompressConfig configures a Compressor.
+type CompressConfig struct {
+	// Encoding encoding of the request bodies, gzip or deflate. Default is gzip
+	Encoding string
+	// Level compression level. Default is the default compression level
+	Level int
+	// MinSize request bodies smaller than it are not compressed, bodies of unknown size are compressed. Default is 1KB
+	MinSize int64
+	// MaxBufferSize rebuildable request bodies of known size up to it are compressed into memory,
+	// larger bodies are compressed while they are sent. Default is 1MB
+	MaxBufferSize int64
+	// DisableRequest disables the compression of request bodies
+	DisableRequest bool
+	// MaxDecompressedSize maximum size of a decompressed response body, reading more fails with
+	// ErrDecompressedTooLarge. Default is 32MB, less than 0 means no limit
+	MaxDecompressedSize int64
+}
+
+// Compressor compresses request bodies and decompresses responses.
+type Compressor struct {
+	cfg CompressConfig
+}
+
+// NewCompressor creates a new Compressor.
+func NewCompressor(cfg CompressConfig) (*Compressor, error) {
+	switch cfg.Encoding {
+	case "":
+		cfg.Encoding = encodingGzip
+	case encodingGzip, encodingDeflate:
+	default:
+		return nil, fmt.Errorf("httpz: unsupported compression encoding %q", cfg.Encoding)
+	}
+	if cfg.Level == 0 {
+		cfg.Level = flate.DefaultCompression
+	}
+	if cfg.Level < flate.HuffmanOnly || cfg.Level > flate.BestCompression {
+		return nil, fmt.Errorf("httpz: invalid compression level %d", cfg.Level)
+	}
+	if cfg.MinSize <= 0 {
+		cfg.MinSize = defaultCompressMinSize
+	}
+	if cfg.MaxBufferSize <= 0 {
+		cfg.MaxBufferSize = defaultCompressBufferSize
+	}
+	if cfg.MaxDecompressedSize == 0 {
+		cfg.MaxDecompressedSize = defaultMaxDecompressedSize
+	}
+
+	return &Compressor{cfg: cfg}, nil
+}
+
+// Middleware returns the compression middleware.
+// Small request bodies which can be rebuilt are compressed into memory, other bodies are compressed while
+// they are sent, and GetBody returns the compressed body in the same way.
+// Accept-Encoding is set if absent, and gzip or deflate responses are decompressed even if the transport
+// did not negotiate the compression.
+func (c *Compressor) Middleware() Middleware {
+	return func(next DoFunc) DoFunc {
+		return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			if !c.cfg.DisableRequest {
+				compressed, err := c.compressRequest(ctx, req)
+				if err != nil {
+					return nil, err
+				}
+				req = compressed
+			}
+
+			if req.Header.Get("Accept-Encoding") == "" {
+				req.Header.Set("Accept-Encoding", acceptEncodings)
+			}
+
+			resp, err := next(ctx, req)
+			if err != nil {
+				return resp, err
+			}
+
+			c.decompressResponse(resp)
+			return resp, nil
+		}
+	}
+}
+
+// compressRequest returns a copy of the request with the compressed body, or req if it is not compressed.
+func (c *Compressor) compressRequest(ctx context.Context, req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody || req.Header.Get("Content-Encoding") != "" {
+		return req, nil
+	}
+	// ContentLength 0 with a body means the size is unknown
+	if req.ContentLength > 0 && req.ContentLength < c.cfg.MinSize {
+		return req, nil
+	}
+
+	out := req.Clone(ctx)
+	out.Header.Set("Content-Encoding", c.cfg.Encoding)
+
+	if req.GetBody == nil || req.ContentLength <= 0 || req.ContentLength > c.cfg.MaxBufferSize {
+		out.Body = c.compressBody(req.Body)
+		if req.GetBody != nil {
+			out.GetBody = func() (io.ReadCloser, error) {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				return c.compressBody(body), nil
+			}
+		}
+		out.ContentLength = -1
+		return out, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err = c.compress(&buf, body)
+	_ = body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("httpz: compress request body failed: %w", err)
+	}
+	_ = req.Body.Close()
+
+	compressed := buf.Bytes()
+	out.Body = io.NopCloser(bytes.NewReader(compressed))
+	out.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(compressed)), nil
+	}
+	out.ContentLength = int64(len(compressed))
+	return out, nil
+}
+
+// compressBody returns the body compressed through a pipe while it is read, body is closed with the returned body.
+func (c *Compressor) compressBody(body io.ReadCloser) io.ReadCloser {
+	return &lazyPipe{
+		produce: func(w io.Writer) error {
+			return c.compress(w, body)
+		},
+		src: body,
+	}
+}
+
+func (c *Compressor) compress(w io.Writer, r io.Reader) error {
+	var zw io.WriteCloser
+	var err error
+	if c.cfg.Encoding == encodingDeflate {
+		zw, err = zlib.NewWriterLevel(w, c.cfg.Level)
+	} else {
+		zw, err = gzip.NewWriterLevel(w, c.cfg.Level)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(zw, r); err != nil {
+		_ = zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// decompressResponse replaces the body of a gzip or deflate response with the decompressed body.
+func (c *Compressor) decompressResponse(resp *http.Response) {
+	if resp == nil || resp.Body == nil || resp.Body == http.NoBody {
+		return
+	}
+
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if encoding != encodingGzip && encoding != encodingDeflate {
+		return
+	}
+
+	resp.Body = &decompressBody{body: resp.Body, encoding: encoding, limit: c.cfg.MaxDecompressedSize}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
+
+// decompressBody decompresses the body lazily on the first read.
+type decompressBody struct {
+	body     io.ReadCloser
+	encoding string
+	limit    int64
+	r        io.Reader
+	n        int64
+	err      error
+}
+
+func (b *decompressBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	if b.r == nil {
+		b.r, b.err = newDecompressor(b.body, b.encoding)
+		if b.err != nil {
+			return 0, b.err
+		}
+	}
+
+	if b.limit > 0 && int64(len(p)) > b.limit-b.n+1 {
+		p = p[:b.limit-b.n+1]
+	}
+
+	n, err := b.r.Read(p)
+	b.n += int64(n)
+	if b.limit > 0 && b.n > b.limit {
+		b.err = ErrDecompressedTooLarge
+		return n - int(b.n-b.limit), b.err
+	}
+	if err != nil {
+		b.err = err
+	}
+	return n, err
+}
+
+func (b *decompressBody) Close() error {
+	if closer, ok := b.r.(io.Closer); ok {
+		_ = closer.Close()
+	}
+	return b.body.Close()
+}
+
+// newDecompressor returns the decompressor of the encoding,
+// deflate bodies are zlib streams by the spec, raw deflate streams are also accepted.
+func newDecompressor(r io.Reader, encoding string) (io.Reader, error) {
+	if encoding == encodingGzip {
+		return gzip.NewReader(r)
+	}
+
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil {
+		if err == io.EOF {
+			return br, nil
+		}
+		return nil, err
+	}
+
+	// zlib header: compression method 8 and the header checksum
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
@@ -0,0 +1,191 @@
+package httpz
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCompressor_Request(t *testing.T) {
+	payload := strings.Repeat("hello world ", 200)
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		switch r.Header.Get("Content-Encoding") {
+		case "gzip":
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			body = zr
+		case "deflate":
+			zr, err := zlib.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			body = zr
+		}
+
+		b, _ := io.ReadAll(body)
+		if r.URL.Path == "/retry" && atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(r.Header.Get("Content-Encoding") + ":" + string(b)))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	gz, _ := NewCompressor(CompressConfig{})
+	client := NewClient(WithMiddleware(gz.Middleware()), WithRetryPolicy(RetryPolicy{
+		MaxRetries:     1,
+		IdempotencyKey: NewIdempotencyKey,
+	}))
+
+	out, err := Post[string](ctx, client, server.URL+"/retry", nil, payload, nil)
+	if err != nil || out != "gzip:"+payload || atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("unexpected response: %.20s %v, calls %d", out, err, calls)
+	}
+
+	out, _ = Post[string](ctx, client, server.URL, nil, "small", nil)
+	if out != ":small" {
+		t.Errorf("expected small body not compressed, got %s", out)
+	}
+
+	out, _ = Post[string](ctx, client, server.URL, nil, io.NopCloser(strings.NewReader(payload)), nil)
+	if out != "gzip:"+payload {
+		t.Errorf("unexpected streamed response: %.20s", out)
+	}
+
+	// bodies larger than MaxBufferSize are streamed, the retry rebuilds them by the original GetBody
+	atomic.StoreInt32(&calls, 0)
+	var rebuilt int32
+	req, _ := http.NewRequest(http.MethodPut, server.URL+"/retry", strings.NewReader(payload))
+	getBody := req.GetBody
+	req.GetBody = func() (io.ReadCloser, error) {
+		atomic.AddInt32(&rebuilt, 1)
+		return getBody()
+	}
+	stream, _ := NewCompressor(CompressConfig{MaxBufferSize: 1 << 10})
+	resp, err := NewClient(WithMiddleware(stream.Middleware()), WithRetryPolicy(RetryPolicy{MaxRetries: 1})).Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(b) != "gzip:"+payload || atomic.LoadInt32(&calls) != 2 || atomic.LoadInt32(&rebuilt) != 1 {
+		t.Errorf("unexpected streamed retry response: %.20s, calls %d, rebuilt %d", b, calls, rebuilt)
+	}
+
+	deflate, _ := NewCompressor(CompressConfig{Encoding: "deflate", Level: flate.BestSpeed})
+	client = NewClient(WithMiddleware(deflate.Middleware()))
+	out, _ = Post[string](ctx, client, server.URL, nil, payload, nil)
+	if out != "deflate:"+payload {
+		t.Errorf("unexpected deflate response: %.20s", out)
+	}
+
+	if _, err = NewCompressor(CompressConfig{Encoding: "br"}); err == nil {
+		t.Error("expected error on unsupported encoding")
+	}
+}
+
+func TestCompressor_UnsentBody(t *testing.T) {
+	var closed int32
+	src := &closeFunc{Reader: strings.NewReader("data"), fn: func() { atomic.AddInt32(&closed, 1) }}
+	req, _ := http.NewRequest(http.MethodPost, "http://service", src)
+
+	gz, _ := NewCompressor(CompressConfig{})
+	out, err := gz.compressRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err = out.Body.Close(); err != nil || atomic.LoadInt32(&closed) != 1 {
+		t.Errorf("expected the unsent body to close its source, got %d %v", closed, err)
+	}
+	if _, err = out.Body.Read(make([]byte, 1)); err != io.ErrClosedPipe {
+		t.Errorf("expected io.ErrClosedPipe, got %v", err)
+	}
+
+	// a large rebuildable body is not compressed into memory
+	var rebuilt int32
+	req, _ = http.NewRequest(http.MethodPut, "http://service", strings.NewReader(strings.Repeat("a", 2<<20)))
+	getBody := req.GetBody
+	req.GetBody = func() (io.ReadCloser, error) {
+		atomic.AddInt32(&rebuilt, 1)
+		return getBody()
+	}
+	if out, _ = gz.compressRequest(context.Background(), req); out.ContentLength != -1 || atomic.LoadInt32(&rebuilt) != 0 {
+		t.Errorf("expected the body to be streamed, got length %d, rebuilt %d", out.ContentLength, rebuilt)
+	}
+	_ = out.Body.Close()
+}
+
+func TestCompressor_Response(t *testing.T) {
+	payload := strings.Repeat("a", 4096)
+	encode := func(encoding string) []byte {
+		var buf bytes.Buffer
+		var w io.WriteCloser
+		switch encoding {
+		case "gzip":
+			w = gzip.NewWriter(&buf)
+		case "deflate":
+			w = zlib.NewWriter(&buf)
+		case "raw":
+			w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+		}
+		_, _ = w.Write([]byte(payload))
+		_ = w.Close()
+		return buf.Bytes()
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip, deflate" {
+			t.Errorf("unexpected Accept-Encoding: %s", r.Header.Get("Accept-Encoding"))
+		}
+
+		encoding := strings.TrimPrefix(r.URL.Path, "/")
+		if encoding == "raw" {
+			w.Header().Set("Content-Encoding", "deflate")
+		} else {
+			w.Header().Set("Content-Encoding", encoding)
+		}
+		_, _ = w.Write(encode(encoding))
+	}))
+	defer server.Close()
+
+	c, _ := NewCompressor(CompressConfig{})
+	client := NewClient(WithMiddleware(c.Middleware()))
+	for _, encoding := range []string{"gzip", "deflate", "raw"} {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/"+encoding, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		b, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil || string(b) != payload || resp.Header.Get("Content-Encoding") != "" || !resp.Uncompressed {
+			t.Errorf("%s: unexpected body: %.20s %v", encoding, b, err)
+		}
+	}
+
+	c, _ = NewCompressor(CompressConfig{MaxDecompressedSize: 1000})
+	client = NewClient(WithMiddleware(c.Middleware()))
+	_, err := Get[string](context.Background(), client, server.URL+"/gzip", nil, nil)
+	if !errors.Is(err, ErrDecompressedTooLarge) {
+		t.Errorf("expected ErrDecompressedTooLarge, got %v", err)
+	}
+}
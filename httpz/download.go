@@ -0,0 +1,471 @@
+package httpz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/welllog/golib/hashz"
+)
+
+const defaultChunkSize = 8 << 20
+
+var (
+	// ErrChecksumMismatch reports that the digest of the downloaded content differs from the expected one.
+	ErrChecksumMismatch = errors.New("httpz: checksum mismatch")
+	// ErrContentChanged reports that the content changed on the server during the download.
+	ErrContentChanged = errors.New("httpz: content changed during the download")
+)
+
+// DownloadConfig configures a download.
+type DownloadConfig struct {
+	// RetryPolicy policy of resuming the download after errors, the retries start over once an attempt
+	// receives some content. AttemptTimeout is ignored. Default is the retry policy of the Client
+	RetryPolicy *RetryPolicy
+	// Concurrency number of parallel ranged chunk requests, 1 or less means a single stream download.
+	// The download falls back to a single stream if the server does not accept ranges.
+	// The destination must support concurrent WriteAt calls if it is greater than 1
+	Concurrency int
+	// ChunkSize size of each chunk of a parallel download. Default is 8MB
+	ChunkSize int64
+	// Checksum expected hex digest of the content, the content is not verified if empty.
+	// The destination must implement io.ReaderAt to be verified
+	Checksum string
+	// Hash computes the hex digest of the content, one of the hashz stream hashes. Default is hashz.Sha256Stream
+	Hash func(r io.Reader) ([]byte, error)
+	// Progress progress callback, it may be called concurrently in a parallel download
+	Progress ProgressFunc
+}
+
+// Download sends the GET request through the client and writes the content into dst, it returns the size of
+// the content. After an error, the download is resumed from the received bytes with a Range request by the
+// retry policy, If-Range carries the ETag or Last-Modified of the content so a changed content is downloaded
+// again from the start. The requests bypass the retry of the Client since the download retries by itself.
+func (c *Client) Download(req *http.Request, dst io.WriterAt, cfg DownloadConfig) (int64, error) {
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize = defaultChunkSize
+	}
+	if cfg.Hash == nil {
+		cfg.Hash = hashz.Sha256Stream
+	}
+
+	if cfg.Checksum != "" {
+		if _, ok := dst.(io.ReaderAt); !ok {
+			return 0, errors.New("httpz: checksum verification requires the destination to implement io.ReaderAt")
+		}
+	}
+
+	d := &downloader{
+		client: c,
+		req:    req,
+		dst:    dst,
+		cfg:    cfg,
+		policy: c.downloadPolicy(cfg.RetryPolicy),
+		total:  -1,
+	}
+
+	ctx := req.Context()
+	if d.policy.TotalTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.policy.TotalTimeout)
+		defer cancel()
+	}
+
+	size, err := d.download(ctx)
+	if err != nil {
+		return size, err
+	}
+
+	if cfg.Checksum != "" {
+		sum, err := cfg.Hash(io.NewSectionReader(dst.(io.ReaderAt), 0, size))
+		if err != nil {
+			return size, fmt.Errorf("httpz: hash downloaded content failed: %w", err)
+		}
+		if !strings.EqualFold(string(sum), cfg.Checksum) {
+			return size, fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, cfg.Checksum, sum)
+		}
+	}
+
+	return size, nil
+}
+
+// DownloadFile downloads the content of the GET request into the file of the path like Download.
+// The file is created if it does not exist, and truncated to the size of the content once the download succeeds.
+func (c *Client) DownloadFile(req *http.Request, path string, cfg DownloadConfig) (int64, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return 0, err
+	}
+
+	size, err := c.Download(req, f, cfg)
+	if err == nil {
+		err = f.Truncate(size)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	return size, err
+}
+
+// downloadPolicy returns the retry policy of a download, the unset functions default to the Client ones.
+func (c *Client) downloadPolicy(policy *RetryPolicy) RetryPolicy {
+	if policy == nil {
+		return c.retryPolicy
+	}
+
+	p := *policy
+	if p.ShouldRetry == nil {
+		p.ShouldRetry = c.retryPolicy.ShouldRetry
+	}
+	if p.Backoff == nil {
+		p.Backoff = c.retryPolicy.Backoff
+	}
+	if p.Budget == nil {
+		p.Budget = c.retryPolicy.Budget
+	}
+	return p
+}
+
+type downloader struct {
+	client  *Client
+	req     *http.Request
+	dst     io.WriterAt
+	cfg     DownloadConfig
+	policy  RetryPolicy
+	written int64
+
+	mu        sync.Mutex
+	total     int64
+	validator string
+}
+
+// span is the range [off, end] of the content remaining to download, end < 0 means the end of the content.
+type span struct {
+	start int64
+	off   int64
+	end   int64
+}
+
+func (d *downloader) download(ctx context.Context) (int64, error) {
+	if d.cfg.Concurrency > 1 {
+		ranged, err := d.probe(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if ranged {
+			return d.total, d.parallel(ctx)
+		}
+	}
+
+	s := &span{end: -1}
+	if err := d.fetch(ctx, s); err != nil {
+		return s.off, err
+	}
+	return s.off, nil
+}
+
+// probe sends a HEAD request and reports whether the content can be downloaded in parallel chunks.
+func (d *downloader) probe(ctx context.Context) (bool, error) {
+	req := d.req.Clone(ctx)
+	req.Method = http.MethodHead
+	req.Body = nil
+	req.GetBody = nil
+	req.ContentLength = 0
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.ContentLength <= d.cfg.ChunkSize ||
+		!strings.EqualFold(resp.Header.Get("Accept-Ranges"), "bytes") {
+		return false, nil
+	}
+
+	d.total = resp.ContentLength
+	d.validator = contentValidator(resp)
+	return true, nil
+}
+
+// parallel downloads the chunks of the content concurrently, the first error cancels the other chunks.
+func (d *downloader) parallel(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	chunks := make(chan *span)
+	go func() {
+		defer close(chunks)
+		for start := int64(0); start < d.total; start += d.cfg.ChunkSize {
+			end := start + d.cfg.ChunkSize - 1
+			if end >= d.total {
+				end = d.total - 1
+			}
+
+			select {
+			case chunks <- &span{start: start, off: start, end: end}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for i := 0; i < d.cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for s := range chunks {
+				if err := d.fetch(ctx, s); err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return firstErr
+}
+
+// fetch downloads the span, and resumes it after errors by the retry policy.
+func (d *downloader) fetch(ctx context.Context, s *span) error {
+	if d.policy.Budget != nil {
+		d.policy.Budget.Deposit()
+	}
+
+	var failures int
+	var delay time.Duration
+	for {
+		n, resp, retryable, err := d.attempt(ctx, s)
+		if err == nil {
+			return nil
+		}
+
+		// received content resets the retries
+		if n > 0 {
+			failures, delay = 0, 0
+		}
+		failures++
+
+		if !retryable || failures > d.policy.MaxRetries {
+			return err
+		}
+		if d.policy.Budget != nil && !d.policy.Budget.Withdraw() {
+			return err
+		}
+
+		delay = retryDelay(&d.policy, failures, delay, resp)
+		if waitErr := sleepContext(ctx, delay); waitErr != nil {
+			return waitErr
+		}
+	}
+}
+
+// attempt requests the remaining range of the span and writes the received content, it returns the number of
+// bytes written, the failed response for the retry delay, and whether the error can be retried.
+func (d *downloader) attempt(ctx context.Context, s *span) (int64, *http.Response, bool, error) {
+	req := d.req.Clone(ctx)
+	if s.off > 0 || s.end >= 0 {
+		rng := "bytes=" + strconv.FormatInt(s.off, 10) + "-"
+		if s.end >= 0 {
+			rng += strconv.FormatInt(s.end, 10)
+		}
+		req.Header.Set("Range", rng)
+
+		if validator := d.contentValidator(); validator != "" {
+			req.Header.Set("If-Range", validator)
+		}
+	}
+
+	resp, err := d.client.DoWithoutRetry(req)
+	if err != nil {
+		return 0, nil, d.policy.ShouldRetry(nil, err), err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		first, _, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || first != s.off {
+			return 0, nil, false, fmt.Errorf("httpz: unexpected content range %q of the range from %d",
+				resp.Header.Get("Content-Range"), s.off)
+		}
+		if err = d.setContent(resp, total, false); err != nil {
+			return 0, nil, false, err
+		}
+
+	case http.StatusOK:
+		// the range is ignored, or the content changed
+		if s.start != 0 || s.end >= 0 {
+			return 0, nil, false, ErrContentChanged
+		}
+		if s.off > 0 {
+			atomic.AddInt64(&d.written, -s.off)
+			s.off = 0
+		}
+		if err = d.setContent(resp, resp.ContentLength, true); err != nil {
+			return 0, nil, false, err
+		}
+
+	case http.StatusRequestedRangeNotSatisfiable:
+		d.mu.Lock()
+		done := s.end < 0 && s.off > 0 && s.off == d.total
+		d.mu.Unlock()
+		if done {
+			return 0, nil, false, nil
+		}
+		return 0, resp, false, newHTTPError(resp, nil)
+
+	default:
+		return 0, resp, d.policy.ShouldRetry(resp, nil), newHTTPError(resp, nil)
+	}
+
+	var body io.Reader = resp.Body
+	if s.end >= 0 {
+		body = io.LimitReader(resp.Body, s.end-s.off+1)
+	}
+
+	w := &offsetWriter{d: d, off: s.off}
+	_, err = io.Copy(w, body)
+	n := w.off - s.off
+	s.off = w.off
+
+	if w.err != nil {
+		return n, nil, false, fmt.Errorf("httpz: write downloaded content failed: %w", w.err)
+	}
+	if err == nil && !d.complete(s) {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return n, nil, d.policy.ShouldRetry(nil, err), err
+	}
+
+	return n, nil, false, nil
+}
+
+// setContent records the total size and the validator of the content,
+// a different total size means the content changed unless reset is true.
+func (d *downloader) setContent(resp *http.Response, total int64, reset bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !reset && d.total >= 0 && total >= 0 && total != d.total {
+		return ErrContentChanged
+	}
+
+	if reset || d.total < 0 {
+		d.total = total
+	}
+	if reset || d.validator == "" {
+		d.validator = contentValidator(resp)
+	}
+	return nil
+}
+
+func (d *downloader) contentValidator() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.validator
+}
+
+// complete reports whether the span is fully downloaded.
+func (d *downloader) complete(s *span) bool {
+	if s.end >= 0 {
+		return s.off == s.end+1
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.total < 0 || s.off == d.total
+}
+
+func (d *downloader) progress(n int64) {
+	written := atomic.AddInt64(&d.written, n)
+	if d.cfg.Progress == nil {
+		return
+	}
+
+	d.mu.Lock()
+	total := d.total
+	d.mu.Unlock()
+	d.cfg.Progress(written, total)
+}
+
+// offsetWriter writes to the destination of the downloader from the offset.
+type offsetWriter struct {
+	d   *downloader
+	off int64
+	err error
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.d.dst.WriteAt(p, w.off)
+	w.off += int64(n)
+	if n > 0 {
+		w.d.progress(int64(n))
+	}
+	if err != nil {
+		w.err = err
+	}
+	return n, err
+}
+
+// contentValidator returns the validator of the If-Range header, weak ETags cannot be used by If-Range.
+func contentValidator(resp *http.Response) string {
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return resp.Header.Get("Last-Modified")
+}
+
+// parseContentRange parses the Content-Range header of the form "bytes first-last/total",
+// total is -1 if it is unknown.
+func parseContentRange(v string) (first, last, total int64, ok bool) {
+	v = strings.TrimSpace(v)
+	if !strings.HasPrefix(v, "bytes ") {
+		return 0, 0, 0, false
+	}
+	v = v[len("bytes "):]
+
+	rng, size, ok := strings.Cut(v, "/")
+	if !ok {
+		return 0, 0, 0, false
+	}
+
+	firstStr, lastStr, ok := strings.Cut(rng, "-")
+	if !ok {
+		return 0, 0, 0, false
+	}
+
+	var err error
+	if first, err = strconv.ParseInt(firstStr, 10, 64); err != nil || first < 0 {
+		return 0, 0, 0, false
+	}
+	if last, err = strconv.ParseInt(lastStr, 10, 64); err != nil || last < first {
+		return 0, 0, 0, false
+	}
+
+	total = -1
+	if size != "*" {
+		if total, err = strconv.ParseInt(size, 10, 64); err != nil || total <= last {
+			return 0, 0, 0, false
+		}
+	}
+
+	return first, last, total, true
+}
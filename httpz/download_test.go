@@ -0,0 +1,270 @@
+package httpz
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/welllog/golib/hashz"
+)
+
+// memFile is an in-memory io.WriterAt and io.ReaderAt.
+type memFile struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+func (f *memFile) WriteAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if end := int(off) + len(p); end > len(f.data) {
+		f.data = append(f.data, make([]byte, end-len(f.data))...)
+	}
+	return copy(f.data[off:], p), nil
+}
+
+func (f *memFile) ReadAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if off >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// abortWriter aborts the response after limit bytes of the body are written.
+type abortWriter struct {
+	http.ResponseWriter
+	limit int
+}
+
+func (w *abortWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		_, _ = w.ResponseWriter.Write(p[:w.limit])
+		w.ResponseWriter.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}
+	w.limit -= len(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func downloadContent(size int) []byte {
+	return bytes.Repeat([]byte("0123456789abcdef"), size/16)
+}
+
+func TestClient_Download(t *testing.T) {
+	content := downloadContent(64 << 10)
+	var mu sync.Mutex
+	var ranges, ifRanges []string
+	var requests int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		ifRanges = append(ifRanges, r.Header.Get("If-Range"))
+		mu.Unlock()
+
+		w.Header().Set("ETag", `"v1"`)
+		if n <= 2 {
+			// the first two attempts are cut off
+			w = &abortWriter{ResponseWriter: w, limit: 10 << 10}
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithRetryPolicy(RetryPolicy{MaxRetries: 1}))
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+
+	var progress int64
+	f := &memFile{}
+	size, err := client.Download(req, f, DownloadConfig{
+		Checksum: hashz.Sha256ToString(content),
+		Progress: func(written, total int64) { atomic.StoreInt64(&progress, written) },
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if size != int64(len(content)) || !bytes.Equal(f.data, content) {
+		t.Fatalf("expected the content of %d bytes, got %d bytes", len(content), size)
+	}
+	if progress != size {
+		t.Errorf("expected progress %d, got %d", size, progress)
+	}
+
+	expectedRanges := []string{"", "bytes=10240-", "bytes=20480-"}
+	if strings.Join(ranges, ",") != strings.Join(expectedRanges, ",") {
+		t.Errorf("expected ranges %v, got %v", expectedRanges, ranges)
+	}
+	if ifRanges[1] != `"v1"` || ifRanges[2] != `"v1"` {
+		t.Errorf("expected If-Range of the ETag, got %v", ifRanges)
+	}
+}
+
+func TestClient_DownloadChanged(t *testing.T) {
+	contentV1 := downloadContent(32 << 10)
+	contentV2 := bytes.ToUpper(downloadContent(40 << 10))
+	var requests int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("ETag", `"v1"`)
+			http.ServeContent(&abortWriter{ResponseWriter: w, limit: 8 << 10}, r, "", time.Time{},
+				bytes.NewReader(contentV1))
+			return
+		}
+		w.Header().Set("ETag", `"v2"`)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(contentV2))
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	f := &memFile{}
+	size, err := NewClient().Download(req, f, DownloadConfig{RetryPolicy: &RetryPolicy{MaxRetries: 1}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if size != int64(len(contentV2)) || !bytes.Equal(f.data[:size], contentV2) {
+		t.Errorf("expected the changed content to be downloaded again")
+	}
+}
+
+func TestClient_DownloadFileParallel(t *testing.T) {
+	content := downloadContent(100 << 10)
+	var mu sync.Mutex
+	ranges := make(map[string]bool)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			mu.Lock()
+			first := !ranges[r.Header.Get("Range")]
+			ranges[r.Header.Get("Range")] = true
+			mu.Unlock()
+
+			// the first attempt of each chunk is cut off
+			if first {
+				w = &abortWriter{ResponseWriter: w, limit: 1 << 10}
+			}
+		}
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "download")
+	if err := os.WriteFile(path, bytes.Repeat([]byte("x"), 200<<10), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	size, err := NewClient().DownloadFile(req, path, DownloadConfig{
+		RetryPolicy: &RetryPolicy{MaxRetries: 1},
+		Concurrency: 3,
+		ChunkSize:   16 << 10,
+		Checksum:    strings.ToUpper(hashz.Sha256ToString(content)),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, _ := os.ReadFile(path)
+	if size != int64(len(content)) || !bytes.Equal(got, content) {
+		t.Fatalf("expected the file of %d bytes, got %d bytes", len(content), len(got))
+	}
+	if !ranges["bytes=0-16383"] || !ranges["bytes=98304-102399"] {
+		t.Errorf("expected ranged chunk requests, got %v", ranges)
+	}
+}
+
+func TestClient_DownloadErrors(t *testing.T) {
+	content := downloadContent(16 << 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Path == "/abort" {
+			w = &abortWriter{ResponseWriter: w, limit: 1 << 10}
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		_, err := NewClient().Download(req, &memFile{}, DownloadConfig{
+			Checksum: hashz.Md5ToString(content),
+			Hash:     hashz.Sha1Stream,
+		})
+		if !errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("expected ErrChecksumMismatch, got %v", err)
+		}
+	})
+
+	t.Run("checksum without io.ReaderAt", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		_, err := NewClient().Download(req, struct{ io.WriterAt }{&memFile{}}, DownloadConfig{Checksum: "00"})
+		if err == nil {
+			t.Error("expected error")
+		}
+	})
+
+	t.Run("status error", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/missing", nil)
+		_, err := NewClient().Download(req, &memFile{}, DownloadConfig{})
+		var httpErr *HTTPError
+		if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusNotFound {
+			t.Errorf("expected 404 HTTPError, got %v", err)
+		}
+	})
+
+	t.Run("retries exhausted", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/abort", nil)
+		size, err := NewClient().Download(req, &memFile{}, DownloadConfig{})
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if size != 1<<10 {
+			t.Errorf("expected %d bytes received, got %d", 1<<10, size)
+		}
+	})
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		value              string
+		first, last, total int64
+		ok                 bool
+	}{
+		{"bytes 0-99/1000", 0, 99, 1000, true},
+		{"bytes 100-199/*", 100, 199, -1, true},
+		{"bytes 100-99/1000", 0, 0, 0, false},
+		{"bytes 0-99/50", 0, 0, 0, false},
+		{"bytes */1000", 0, 0, 0, false},
+		{"items 0-99/1000", 0, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		first, last, total, ok := parseContentRange(tt.value)
+		if ok != tt.ok || first != tt.first || last != tt.last || total != tt.total {
+			t.Errorf("%q: expected %d %d %d %v, got %d %d %d %v", tt.value,
+				tt.first, tt.last, tt.total, tt.ok, first, last, total, ok)
+		}
+	}
+}
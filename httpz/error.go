@@ -0,0 +1,123 @@
+package httpz
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/welllog/golib/strz"
+)
+
+const maxErrorBodySize = 4 << 10
+
+var (
+	// ErrClientStatus matches an *HTTPError with a 4xx status code.
+	ErrClientStatus = errors.New("httpz: client error status")
+	// ErrServerStatus matches an *HTTPError with a 5xx status code.
+	ErrServerStatus = errors.New("httpz: server error status")
+)
+
+// HTTPError is returned when the response status code is not 2xx.
+type HTTPError struct {
+	// Method method of the request
+	Method string
+	// URL url of the request
+	URL string
+	// StatusCode status code of the response
+	StatusCode int
+	// Header header of the response
+	Header http.Header
+	// Body body of the response, truncated to 4KB
+	Body []byte
+	// Truncated reports whether Body was truncated
+	Truncated bool
+
+	codec Codec
+}
+
+// newHTTPError reads the truncated body and closes it.
+func newHTTPError(resp *http.Response, codec Codec) *HTTPError {
+	e := &HTTPError{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		codec:      codec,
+	}
+
+	if resp.Request != nil {
+		e.Method = resp.Request.Method
+		if resp.Request.URL != nil {
+			e.URL = resp.Request.URL.Redacted()
+		}
+	}
+
+	if resp.Body != nil {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize+1))
+		if len(b) > maxErrorBodySize {
+			b = b[:maxErrorBodySize]
+			e.Truncated = true
+		}
+		e.Body = b
+		_ = resp.Body.Close()
+	}
+
+	return e
+}
+
+func (e *HTTPError) Error() string {
+	msg := "http status " + strconv.Itoa(e.StatusCode)
+	if text := http.StatusText(e.StatusCode); text != "" {
+		msg += " " + text
+	}
+	if e.Method != "" {
+		msg = e.Method + " " + e.URL + ": " + msg
+	}
+	if len(e.Body) > 0 {
+		msg += ", body: " + strz.UnsafeString(e.Body)
+	}
+	return msg
+}
+
+func (e *HTTPError) Is(target error) bool {
+	switch target {
+	case ErrClientStatus:
+		return e.IsClientError()
+	case ErrServerStatus:
+		return e.IsServerError()
+	default:
+		return false
+	}
+}
+
+// IsClientError reports whether the status code is 4xx.
+func (e *HTTPError) IsClientError() bool {
+	return e.StatusCode >= 400 && e.StatusCode < 500
+}
+
+// IsServerError reports whether the status code is 5xx.
+func (e *HTTPError) IsServerError() bool {
+	return e.StatusCode >= 500
+}
+
+// Decode decodes the body into v through the codec of the request.
+func (e *HTTPError) Decode(v any) error {
+	if e.codec == nil {
+		return errors.New("httpz: no codec to decode error body")
+	}
+	return unmarshal(e.codec, e.Header.Get("Content-Type"), e.Body, v)
+}
+
+// ErrorAs finds the first *HTTPError in err's chain and decodes its body into E.
+// It returns false if there is no *HTTPError or the body cannot be decoded.
+func ErrorAs[E any](err error) (E, bool) {
+	var payload E
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		return payload, false
+	}
+
+	if len(httpErr.Body) == 0 || httpErr.Truncated || httpErr.Decode(&payload) != nil {
+		return payload, false
+	}
+	return payload, true
+}
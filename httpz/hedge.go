@@ -0,0 +1,293 @@
+package httpz
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultHedgeDelay      = 100 * time.Millisecond
+	hedgeLatencySamples    = 256
+	hedgeMinLatencySamples = 20
+)
+
+// HedgeConfig configures a Hedger.
+type HedgeConfig struct {
+	// Delay before sending each hedged request. Default is 100ms
+	Delay time.Duration
+	// Percentile if greater than 0, e.g. 0.95, the delay is this latency percentile of recent requests.
+	// Delay is used until enough latency samples are collected
+	Percentile float64
+	// MaxHedges maximum number of hedged requests besides the original one. Default is 1
+	MaxHedges int
+	// ShouldHedge reports whether the request can be hedged. Default only hedges GET, HEAD and OPTIONS requests
+	ShouldHedge func(req *http.Request) bool
+	// IsFailure failure judgment function, a failed response does not win. Default is DefaultFailureFunc
+	IsFailure RetryableFunc
+}
+
+// HedgeStats counters of a Hedger since it was created.
+type HedgeStats struct {
+	// Requests number of requests hedging was applied to
+	Requests uint64
+	// Hedges number of hedged requests issued
+	Hedges uint64
+	// Wins number of requests won by a hedged request
+	Wins uint64
+}
+
+// Hedger sends extra copies of a slow request and returns the first successful response.
+type Hedger struct {
+	cfg      HedgeConfig
+	requests uint64
+	hedges   uint64
+	wins     uint64
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	next      int
+}
+
+type hedgeResult struct {
+	index int
+	resp  *http.Response
+	err   error
+	cost  time.Duration
+}
+
+// NewHedger creates a new Hedger.
+func NewHedger(cfg HedgeConfig) *Hedger {
+	if cfg.Delay <= 0 {
+		cfg.Delay = defaultHedgeDelay
+	}
+	if cfg.MaxHedges <= 0 {
+		cfg.MaxHedges = 1
+	}
+	if cfg.ShouldHedge == nil {
+		cfg.ShouldHedge = idempotentRead
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = DefaultFailureFunc
+	}
+
+	return &Hedger{
+		cfg:       cfg,
+		latencies: make([]time.Duration, 0, hedgeLatencySamples),
+	}
+}
+
+// Stats returns the counters of the Hedger.
+func (h *Hedger) Stats() HedgeStats {
+	return HedgeStats{
+		Requests: atomic.LoadUint64(&h.requests),
+		Hedges:   atomic.LoadUint64(&h.hedges),
+		Wins:     atomic.LoadUint64(&h.wins),
+	}
+}
+
+// Middleware returns the hedging middleware.
+// Hedged requests rebuild their bodies through req.GetBody, requests whose body cannot be rebuilt are not hedged.
+// The losing requests are canceled and their responses drained in background.
+func (h *Hedger) Middleware() Middleware {
+	return func(next DoFunc) DoFunc {
+		return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			if !h.cfg.ShouldHedge(req) || !canRebuildBody(req) {
+				return next(ctx, req)
+			}
+
+			return h.do(ctx, req, next)
+		}
+	}
+}
+
+func (h *Hedger) do(ctx context.Context, req *http.Request, next DoFunc) (*http.Response, error) {
+	atomic.AddUint64(&h.requests, 1)
+
+	results := make(chan hedgeResult, h.cfg.MaxHedges+1)
+	cancels := make([]context.CancelFunc, 0, h.cfg.MaxHedges+1)
+	launch := func(r *http.Request) {
+		attemptCtx, cancel := context.WithCancel(ctx)
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+
+		begin := time.Now()
+		go func() {
+			resp, err := next(attemptCtx, r)
+			results <- hedgeResult{index: index, resp: resp, err: err, cost: time.Since(begin)}
+		}()
+	}
+
+	// hedged copies are cloned from a template, since req may be modified by the retries of the original request
+	tmpl := req.Clone(ctx)
+	launch(req)
+	inflight := 1
+	canHedge := true
+
+	timer := time.NewTimer(h.delay())
+	defer timer.Stop()
+
+	var last hedgeResult
+	for {
+		var timeout <-chan time.Time
+		if canHedge {
+			timeout = timer.C
+		}
+
+		select {
+		case <-timeout:
+			canHedge = h.hedge(ctx, tmpl, launch, len(cancels))
+			if canHedge {
+				inflight++
+				timer.Reset(h.delay())
+			}
+
+		case r := <-results:
+			inflight--
+			if !h.cfg.IsFailure(r.resp, r.err) {
+				h.observe(r.cost)
+				if r.index > 0 {
+					atomic.AddUint64(&h.wins, 1)
+				}
+				if last.resp != nil {
+					_ = last.resp.Body.Close()
+				}
+
+				for i, cancel := range cancels {
+					if i != r.index {
+						cancel()
+					}
+				}
+				h.drain(results, inflight)
+				return bindCancel(r.resp, r.err, cancels[r.index])
+			}
+
+			// keep the latest failure, and hedge at once if possible
+			if last.resp != nil {
+				_ = last.resp.Body.Close()
+				cancels[last.index]()
+			}
+			if r.resp == nil {
+				cancels[r.index]()
+			}
+			last = r
+
+			if canHedge {
+				canHedge = h.hedge(ctx, tmpl, launch, len(cancels))
+				if canHedge {
+					inflight++
+					timer.Reset(h.delay())
+					continue
+				}
+			}
+
+			if inflight == 0 {
+				if last.resp == nil {
+					return nil, last.err
+				}
+				return bindCancel(last.resp, last.err, cancels[last.index])
+			}
+
+		case <-ctx.Done():
+			for _, cancel := range cancels {
+				cancel()
+			}
+			if last.resp != nil {
+				_ = last.resp.Body.Close()
+			}
+			h.drain(results, inflight)
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// hedge launches a hedged copy of req, and reports whether it was launched.
+func (h *Hedger) hedge(ctx context.Context, req *http.Request, launch func(*http.Request), sent int) bool {
+	if sent > h.cfg.MaxHedges || ctx.Err() != nil {
+		return false
+	}
+
+	clone, err := cloneRequest(ctx, req)
+	if err != nil {
+		return false
+	}
+
+	atomic.AddUint64(&h.hedges, 1)
+	launch(clone)
+	return true
+}
+
+// drain closes the responses of the remaining requests in background.
+func (h *Hedger) drain(results <-chan hedgeResult, inflight int) {
+	if inflight <= 0 {
+		return
+	}
+
+	go func() {
+		for i := 0; i < inflight; i++ {
+			r := <-results
+			if r.resp != nil && r.resp.Body != nil {
+				_ = r.resp.Body.Close()
+			}
+		}
+	}()
+}
+
+// delay returns the delay before the next hedged request.
+func (h *Hedger) delay() time.Duration {
+	if h.cfg.Percentile <= 0 {
+		return h.cfg.Delay
+	}
+
+	h.mu.Lock()
+	if len(h.latencies) < hedgeMinLatencySamples {
+		h.mu.Unlock()
+		return h.cfg.Delay
+	}
+	samples := make([]time.Duration, len(h.latencies))
+	copy(samples, h.latencies)
+	h.mu.Unlock()
+
+	return durationPercentile(samples, h.cfg.Percentile)
+}
+
+func (h *Hedger) observe(cost time.Duration) {
+	if h.cfg.Percentile <= 0 {
+		return
+	}
+
+	h.mu.Lock()
+	if len(h.latencies) < hedgeLatencySamples {
+		h.latencies = append(h.latencies, cost)
+	} else {
+		h.latencies[h.next] = cost
+		h.next = (h.next + 1) % hedgeLatencySamples
+	}
+	h.mu.Unlock()
+}
+
+// durationPercentile returns the p percentile of the samples, the samples will be sorted.
+func durationPercentile(samples []time.Duration, p float64) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	if p >= 1 {
+		return samples[len(samples)-1]
+	}
+
+	return samples[int(p*float64(len(samples)-1)+0.5)]
+}
+
+func idempotentRead(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,172 @@
+package httpz
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedger_SlowFirst(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(time.Second):
+			}
+			_, _ = w.Write([]byte("slow"))
+			return
+		}
+		_, _ = w.Write([]byte("fast"))
+	}))
+	defer server.Close()
+
+	hedger := NewHedger(HedgeConfig{Delay: 20 * time.Millisecond})
+	client := NewClient(WithMiddleware(hedger.Middleware()))
+
+	begin := time.Now()
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if string(body) != "fast" {
+		t.Errorf("expected hedged response 'fast', got '%s'", string(body))
+	}
+	if cost := time.Since(begin); cost > 500*time.Millisecond {
+		t.Errorf("expected hedged response to return early, cost %v", cost)
+	}
+
+	stats := hedger.Stats()
+	if stats.Requests != 1 || stats.Hedges != 1 || stats.Wins != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestHedger_FastFirst(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	hedger := NewHedger(HedgeConfig{Delay: 200 * time.Millisecond})
+	client := NewClient(WithMiddleware(hedger.Middleware()))
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("expected 1 call, got %d", n)
+	}
+	if stats := hedger.Stats(); stats.Hedges != 0 {
+		t.Errorf("expected no hedge, got %+v", stats)
+	}
+}
+
+func TestHedger_Body(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "payload" {
+			t.Errorf("expected body 'payload', got '%s'", string(body))
+		}
+		if n == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	hedger := NewHedger(HedgeConfig{
+		Delay:       time.Second,
+		MaxHedges:   2,
+		ShouldHedge: func(req *http.Request) bool { return true },
+	})
+	client := NewClient(WithMiddleware(hedger.Middleware()))
+
+	req, _ := http.NewRequest(http.MethodPut, server.URL, strings.NewReader("payload"))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// a failed response is hedged at once without waiting for the delay
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got %d", resp.StatusCode)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("expected 2 calls, got %d", n)
+	}
+}
+
+func TestHedger_AllFailed(t *testing.T) {
+	var calls int32
+	hedger := NewHedger(HedgeConfig{Delay: 10 * time.Millisecond, MaxHedges: 2})
+	do := hedger.Middleware()(func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, errors.New("connection refused")
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	if _, err := do(req.Context(), req); err == nil || err.Error() != "connection refused" {
+		t.Errorf("expected the last error, got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("expected 3 calls, got %d", n)
+	}
+}
+
+func TestHedger_NotHedged(t *testing.T) {
+	hedger := NewHedger(HedgeConfig{Delay: time.Millisecond})
+	do := hedger.Middleware()(func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		time.Sleep(20 * time.Millisecond)
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+
+	post, _ := http.NewRequest(http.MethodPost, "http://example.com", strings.NewReader("x"))
+	if _, err := do(post.Context(), post); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	get, _ := http.NewRequest(http.MethodGet, "http://example.com", io.NopCloser(strings.NewReader("x")))
+	if _, err := do(get.Context(), get); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if stats := hedger.Stats(); stats.Requests != 0 || stats.Hedges != 0 {
+		t.Errorf("expected no hedging, got %+v", stats)
+	}
+}
+
+func TestHedger_Percentile(t *testing.T) {
+	hedger := NewHedger(HedgeConfig{Delay: time.Second, Percentile: 0.9})
+	if d := hedger.delay(); d != time.Second {
+		t.Errorf("expected default delay before enough samples, got %v", d)
+	}
+
+	for i := 1; i <= 100; i++ {
+		hedger.observe(time.Duration(i) * time.Millisecond)
+	}
+	if d := hedger.delay(); d != 90*time.Millisecond && d != 91*time.Millisecond {
+		t.Errorf("expected p90 delay about 90ms, got %v", d)
+	}
+}
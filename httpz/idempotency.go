@@ -0,0 +1,26 @@
+package httpz
+
+import (
+	"net/http"
+
+	"github.com/welllog/golib/randz"
+)
+
+// HeaderIdempotencyKey header of the idempotency key, non-idempotent requests with it can be retried.
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// NewIdempotencyKey generates a random idempotency key through randz.Id, it can be used as
+// RetryPolicy.IdempotencyKey.
+func NewIdempotencyKey(req *http.Request) string {
+	return randz.Id().String()
+}
+
+// idempotentMethod reports whether the method is idempotent by RFC 9110.
+func idempotentMethod(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
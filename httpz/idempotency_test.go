@@ -0,0 +1,116 @@
+package httpz
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestClient_IdempotentRetry(t *testing.T) {
+	var mu sync.Mutex
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get(HeaderIdempotencyKey))
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	send := func(client *Client, method, key string) []string {
+		mu.Lock()
+		keys = nil
+		mu.Unlock()
+
+		req, _ := http.NewRequest(method, server.URL, strings.NewReader("x"))
+		if key != "" {
+			req.Header.Set(HeaderIdempotencyKey, key)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_ = resp.Body.Close()
+
+		if req.Header.Get(HeaderIdempotencyKey) != key {
+			t.Errorf("expected the request header not to be changed, got %q", req.Header.Get(HeaderIdempotencyKey))
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), keys...)
+	}
+
+	client := NewClient(WithRetryPolicy(RetryPolicy{MaxRetries: 2}))
+
+	tests := []struct {
+		name     string
+		client   *Client
+		method   string
+		key      string
+		attempts int
+	}{
+		{"idempotent method", client, http.MethodPut, "", 3},
+		{"non-idempotent method", client, http.MethodPost, "", 1},
+		{"non-idempotent method with key", client, http.MethodPatch, "k1", 3},
+		{"retry non-idempotent", NewClient(WithRetryPolicy(RetryPolicy{MaxRetries: 2, RetryNonIdempotent: true})),
+			http.MethodPost, "", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := send(tt.client, tt.method, tt.key)
+			if len(got) != tt.attempts {
+				t.Fatalf("expected %d attempts, got %d", tt.attempts, len(got))
+			}
+			for _, k := range got {
+				if k != tt.key {
+					t.Errorf("expected key %q, got %q", tt.key, k)
+				}
+			}
+		})
+	}
+
+	t.Run("generated key", func(t *testing.T) {
+		client := NewClient(WithRetryPolicy(RetryPolicy{MaxRetries: 2, IdempotencyKey: NewIdempotencyKey}))
+
+		first := send(client, http.MethodPost, "")
+		if len(first) != 3 || first[0] == "" || first[1] != first[0] || first[2] != first[0] {
+			t.Fatalf("expected a stable key of 3 attempts, got %q", first)
+		}
+
+		second := send(client, http.MethodPost, "")
+		if len(second) != 3 || second[0] == first[0] {
+			t.Errorf("expected a new key of another request, got %q", second)
+		}
+
+		if got := send(client, http.MethodPost, "k2"); len(got) != 3 || got[0] != "k2" {
+			t.Errorf("expected the existing key to be kept, got %q", got)
+		}
+		if got := send(client, http.MethodGet, ""); len(got) != 3 || got[0] != "" {
+			t.Errorf("expected no key of an idempotent request, got %q", got)
+		}
+	})
+
+	t.Run("per request policy", func(t *testing.T) {
+		client := NewClient(WithRetryPolicy(RetryPolicy{IdempotencyKey: NewIdempotencyKey}))
+		req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("x"))
+
+		mu.Lock()
+		keys = nil
+		mu.Unlock()
+		resp, err := client.DoWithRetry(req, RetryPolicy{MaxRetries: 1})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_ = resp.Body.Close()
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] {
+			t.Errorf("expected the client key generator to be used, got %q", keys)
+		}
+	})
+}
@@ -0,0 +1,428 @@
+package httpz
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	defaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+	defaultRedactQuery   = []string{"access_token", "api_key", "apikey", "key", "password", "secret", "sig",
+		"signature", "token"}
+	defaultRedactFields = []string{"access_token", "client_secret", "password", "refresh_token", "secret", "token"}
+)
+
+// Logger is the logger of RequestLogger, goz.Logger implementations only need to add Info.
+type Logger interface {
+	Info(args ...any)
+	Error(args ...any)
+}
+
+// LogConfig configures a RequestLogger.
+type LogConfig struct {
+	// Logger receives the log lines. Default logs through the standard log package
+	Logger Logger
+	// MaxBodySize maximum bytes of the request and response bodies to log. Default is 0, means bodies are not logged
+	MaxBodySize int
+	// DumpCurl logs the request as a curl command
+	DumpCurl bool
+	// RedactHeaders header names whose values are redacted, case-insensitive.
+	// Default is Authorization, Proxy-Authorization, Cookie, Set-Cookie and X-Api-Key
+	RedactHeaders []string
+	// RedactQuery query parameter and form field names whose values are redacted, case-insensitive.
+	// Default is access_token, api_key, apikey, key, password, secret, sig, signature and token
+	RedactQuery []string
+	// RedactFields JSON body field names whose values are redacted at any depth, case-insensitive.
+	// Default is access_token, client_secret, password, refresh_token, secret and token
+	RedactFields []string
+}
+
+// RequestLogger logs the requests sent through a Client.
+type RequestLogger struct {
+	cfg     LogConfig
+	headers map[string]struct{}
+	query   map[string]struct{}
+	fields  *regexp.Regexp
+}
+
+// NewRequestLogger creates a new RequestLogger.
+func NewRequestLogger(cfg LogConfig) *RequestLogger {
+	if cfg.Logger == nil {
+		cfg.Logger = stdLogger{}
+	}
+	if cfg.RedactHeaders == nil {
+		cfg.RedactHeaders = defaultRedactHeaders
+	}
+	if cfg.RedactQuery == nil {
+		cfg.RedactQuery = defaultRedactQuery
+	}
+	if cfg.RedactFields == nil {
+		cfg.RedactFields = defaultRedactFields
+	}
+
+	l := &RequestLogger{
+		cfg:     cfg,
+		headers: make(map[string]struct{}, len(cfg.RedactHeaders)),
+		query:   make(map[string]struct{}, len(cfg.RedactQuery)),
+	}
+	for _, h := range cfg.RedactHeaders {
+		l.headers[http.CanonicalHeaderKey(h)] = struct{}{}
+	}
+	for _, q := range cfg.RedactQuery {
+		l.query[strings.ToLower(q)] = struct{}{}
+	}
+	if len(cfg.RedactFields) > 0 {
+		names := make([]string, len(cfg.RedactFields))
+		for i, f := range cfg.RedactFields {
+			names[i] = regexp.QuoteMeta(f)
+		}
+		l.fields = regexp.MustCompile(`(?i)("(?:` + strings.Join(names, "|") +
+			`)"\s*:\s*)("(?:[^"\\]|\\.)*"|[^\s,}\]]+)`)
+	}
+
+	return l
+}
+
+// Middleware returns the logging middleware.
+// A request is logged when its response body is closed or fully read, so the latency and the response size
+// cover the body. Bodies are captured while they are sent and read, the streams are not consumed by the logger.
+func (l *RequestLogger) Middleware() Middleware {
+	return func(next DoFunc) DoFunc {
+		return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			entry := &logEntry{logger: l, req: req, begin: time.Now()}
+			ctx = withAttemptHook(ctx, func(attempt int, resp *http.Response, err error, cost time.Duration) {
+				atomic.StoreInt32(&entry.attempt, int32(attempt))
+			})
+
+			if l.cfg.MaxBodySize > 0 || l.cfg.DumpCurl {
+				entry.reqBody, req = l.captureRequestBody(ctx, req)
+			}
+
+			resp, err := next(ctx, req)
+			if err != nil || resp == nil || resp.Body == nil {
+				entry.resp, entry.err = resp, err
+				entry.log()
+				return resp, err
+			}
+
+			entry.resp = resp
+			resp.Body = &logBody{ReadCloser: resp.Body, entry: entry, max: l.cfg.MaxBodySize}
+			return resp, nil
+		}
+	}
+}
+
+// Curl returns the request as a curl command with the sensitive values redacted.
+// The body is read through req.GetBody, it is omitted if the body cannot be rebuilt.
+func (l *RequestLogger) Curl(req *http.Request) string {
+	var body []byte
+	if req.GetBody != nil {
+		if rc, err := req.GetBody(); err == nil {
+			body, _ = io.ReadAll(rc)
+			_ = rc.Close()
+		}
+	}
+	return l.curl(req, body, false)
+}
+
+// captureRequestBody returns the capped request body, the body is captured through a copy from req.GetBody,
+// or while it is sent by a copy of the request if it cannot be rebuilt.
+func (l *RequestLogger) captureRequestBody(ctx context.Context, req *http.Request) (*capture, *http.Request) {
+	c := &capture{max: l.cfg.MaxBodySize}
+	if c.max <= 0 {
+		c.max = maxErrorBodySize
+	}
+
+	if req.Body == nil || req.Body == http.NoBody {
+		return c, req
+	}
+
+	if req.GetBody != nil {
+		if rc, err := req.GetBody(); err == nil {
+			_, _ = io.Copy(c, io.LimitReader(rc, int64(c.max)+1))
+			_ = rc.Close()
+		}
+		return c, req
+	}
+
+	tee := req.WithContext(ctx)
+	tee.Body = &teeReadCloser{ReadCloser: req.Body, w: c}
+	return c, tee
+}
+
+func (l *RequestLogger) redactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+
+	clone := *u
+	if clone.RawQuery != "" {
+		clone.RawQuery = l.redactValues(clone.RawQuery)
+	}
+	return clone.Redacted()
+}
+
+// redactValues redacts the values of the sensitive names of an url encoded string, the order is kept.
+func (l *RequestLogger) redactValues(s string) string {
+	pairs := strings.Split(s, "&")
+	for i, pair := range pairs {
+		name, _, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if key, err := url.QueryUnescape(name); err == nil {
+			if _, ok := l.query[strings.ToLower(key)]; ok {
+				pairs[i] = name + "=" + url.QueryEscape(redacted)
+			}
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func (l *RequestLogger) redactBody(header http.Header, body []byte) string {
+	switch parseMediaType(header.Get("Content-Type")) {
+	case MIMEForm:
+		return l.redactValues(string(body))
+	default:
+		if l.fields == nil {
+			return string(body)
+		}
+		return l.fields.ReplaceAllString(string(body), `${1}"`+redacted+`"`)
+	}
+}
+
+func (l *RequestLogger) headerValue(key string, values []string) string {
+	if _, ok := l.headers[http.CanonicalHeaderKey(key)]; ok {
+		return redacted
+	}
+	return strings.Join(values, ", ")
+}
+
+func (l *RequestLogger) curl(req *http.Request, body []byte, truncated bool) string {
+	var sb strings.Builder
+	sb.WriteString("curl -X ")
+	sb.WriteString(req.Method)
+	sb.WriteByte(' ')
+	sb.WriteString(shellQuote(l.redactURL(req.URL)))
+
+	keys := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		sb.WriteString(" -H ")
+		sb.WriteString(shellQuote(k + ": " + l.headerValue(k, req.Header[k])))
+	}
+
+	if len(body) > 0 {
+		sb.WriteString(" --data-binary ")
+		sb.WriteString(shellQuote(l.redactBody(req.Header, body)))
+		if truncated {
+			sb.WriteString(" # body truncated")
+		}
+	}
+
+	return sb.String()
+}
+
+type logEntry struct {
+	logger  *RequestLogger
+	req     *http.Request
+	resp    *http.Response
+	err     error
+	begin   time.Time
+	attempt int32
+	reqBody *capture
+	once    sync.Once
+}
+
+func (e *logEntry) log() {
+	e.once.Do(func() {
+		e.write(0, nil)
+	})
+}
+
+func (e *logEntry) logResponse(size int64, body *capture) {
+	e.once.Do(func() {
+		e.write(size, body)
+	})
+}
+
+func (e *logEntry) write(respSize int64, respBody *capture) {
+	l := e.logger
+	var sb strings.Builder
+	sb.Grow(256)
+
+	sb.WriteString("httpz: ")
+	sb.WriteString(e.req.Method)
+	sb.WriteByte(' ')
+	sb.WriteString(l.redactURL(e.req.URL))
+	if e.resp != nil {
+		sb.WriteString(" status=")
+		sb.WriteString(strconv.Itoa(e.resp.StatusCode))
+	}
+	sb.WriteString(" latency=")
+	sb.WriteString(time.Since(e.begin).String())
+	sb.WriteString(" attempt=")
+	sb.WriteString(strconv.Itoa(int(atomic.LoadInt32(&e.attempt))))
+	sb.WriteString(" req_size=")
+	sb.WriteString(strconv.FormatInt(e.requestSize(), 10))
+	if e.resp != nil {
+		sb.WriteString(" resp_size=")
+		sb.WriteString(strconv.FormatInt(respSize, 10))
+	}
+	if e.err != nil {
+		sb.WriteString(" error=")
+		sb.WriteString(strconv.Quote(e.err.Error()))
+	}
+
+	if l.cfg.MaxBodySize > 0 {
+		if body := e.reqBody.bytes(); len(body) > 0 {
+			sb.WriteString(" req_body=")
+			sb.WriteString(strconv.Quote(l.redactBody(e.req.Header, body)))
+		}
+		if body := respBody.bytes(); len(body) > 0 {
+			sb.WriteString(" resp_body=")
+			sb.WriteString(strconv.Quote(l.redactBody(e.resp.Header, body)))
+		}
+	}
+
+	if l.cfg.DumpCurl {
+		sb.WriteString("\n")
+		sb.WriteString(l.curl(e.req, e.reqBody.bytes(), e.reqBody.truncated()))
+	}
+
+	if e.err != nil || (e.resp != nil && e.resp.StatusCode >= 500) {
+		l.cfg.Logger.Error(sb.String())
+	} else {
+		l.cfg.Logger.Info(sb.String())
+	}
+}
+
+func (e *logEntry) requestSize() int64 {
+	if e.req.ContentLength > 0 {
+		return e.req.ContentLength
+	}
+	return e.reqBody.size()
+}
+
+// capture keeps the first max bytes written, and counts all bytes.
+type capture struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	max int
+	n   int64
+}
+
+func (c *capture) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.n += int64(len(p))
+	if remain := c.max - c.buf.Len(); remain > 0 {
+		if len(p) > remain {
+			c.buf.Write(p[:remain])
+		} else {
+			c.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+func (c *capture) bytes() []byte {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]byte(nil), c.buf.Bytes()...)
+}
+
+func (c *capture) size() int64 {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.n
+}
+
+func (c *capture) truncated() bool {
+	return c.size() > int64(len(c.bytes()))
+}
+
+type teeReadCloser struct {
+	io.ReadCloser
+	w io.Writer
+}
+
+func (t *teeReadCloser) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	if n > 0 {
+		_, _ = t.w.Write(p[:n])
+	}
+	return n, err
+}
+
+// logBody logs the entry when the response body is fully read or closed.
+type logBody struct {
+	io.ReadCloser
+	entry *logEntry
+	max   int
+	n     int64
+	body  *capture
+}
+
+func (b *logBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.n += int64(n)
+		if b.max > 0 {
+			if b.body == nil {
+				b.body = &capture{max: b.max}
+			}
+			_, _ = b.body.Write(p[:n])
+		}
+	}
+	if err == io.EOF {
+		b.entry.logResponse(b.n, b.body)
+	}
+	return n, err
+}
+
+func (b *logBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.entry.logResponse(b.n, b.body)
+	return err
+}
+
+type stdLogger struct{}
+
+func (stdLogger) Info(args ...any) {
+	log.Println(args...)
+}
+
+func (stdLogger) Error(args ...any) {
+	log.Println(args...)
+}
+
+// shellQuote quotes s for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
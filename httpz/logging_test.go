@@ -0,0 +1,132 @@
+package httpz
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+type recordLogger struct {
+	mu     sync.Mutex
+	infos  []string
+	errors []string
+}
+
+func (l *recordLogger) Info(args ...any) {
+	l.mu.Lock()
+	l.infos = append(l.infos, fmt.Sprint(args...))
+	l.mu.Unlock()
+}
+
+func (l *recordLogger) Error(args ...any) {
+	l.mu.Lock()
+	l.errors = append(l.errors, fmt.Sprint(args...))
+	l.mu.Unlock()
+}
+
+func (l *recordLogger) lines() ([]string, []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.infos...), append([]string(nil), l.errors...)
+}
+
+func TestRequestLogger(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		if r.URL.Path == "/retry" && atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write(b)
+	}))
+	defer server.Close()
+
+	logger := &recordLogger{}
+	rl := NewRequestLogger(LogConfig{Logger: logger, MaxBodySize: 40, DumpCurl: true})
+	client := NewClient(WithMiddleware(rl.Middleware()), WithRetryPolicy(RetryPolicy{MaxRetries: 1}))
+
+	body := `{"user":"bob","password":"p\"w","nested":{"Token":123}}`
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/retry?a=1&token=abc", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Content-Type", MIMEJSON)
+	req.Header.Set(HeaderIdempotencyKey, "k1")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if infos, _ := logger.lines(); len(infos) != 0 {
+		t.Errorf("expected no log before the body is read, got %v", infos)
+	}
+
+	b, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(b) != body {
+		t.Errorf("the response body was consumed by the logger: %s", b)
+	}
+
+	infos, _ := logger.lines()
+	if len(infos) != 1 {
+		t.Fatalf("expected 1 log line, got %v", infos)
+	}
+
+	line := infos[0]
+	for _, want := range []string{
+		"POST " + server.URL + "/retry?a=1&token=%5BREDACTED%5D",
+		"status=200",
+		"attempt=2",
+		fmt.Sprintf("req_size=%d", len(body)),
+		fmt.Sprintf("resp_size=%d", len(body)),
+		`\"password\":\"[REDACTED]\"`,
+		"-H 'Authorization: [REDACTED]'",
+		"--data-binary",
+		"# body truncated",
+	} {
+		if !strings.Contains(line, want) {
+			t.Errorf("expected %q in log line: %s", want, line)
+		}
+	}
+	for _, leak := range []string{"secret", "abc", `p\"w`, "123"} {
+		if strings.Contains(line, leak) {
+			t.Errorf("unexpected %q in log line: %s", leak, line)
+		}
+	}
+}
+
+func TestRequestLogger_Error(t *testing.T) {
+	logger := &recordLogger{}
+	client := NewClient(WithMiddleware(NewRequestLogger(LogConfig{Logger: logger}).Middleware()))
+
+	form := "name=bob&password=x"
+	req, _ := http.NewRequest(http.MethodPost, "http://127.0.0.1:1/login", io.NopCloser(strings.NewReader(form)))
+	req.Header.Set("Content-Type", MIMEForm)
+	if _, err := client.DoWithoutRetry(req.WithContext(context.Background())); err == nil {
+		t.Fatal("expected connection error")
+	}
+
+	_, errs := logger.lines()
+	if len(errs) != 1 || !strings.Contains(errs[0], "attempt=1") || !strings.Contains(errs[0], "error=") {
+		t.Errorf("unexpected error logs: %v", errs)
+	}
+
+	rl := NewRequestLogger(LogConfig{})
+	req, _ = http.NewRequest(http.MethodPost, "http://example.com/login?x=1", strings.NewReader(form))
+	req.Header.Set("Content-Type", MIMEForm)
+	want := `curl -X POST 'http://example.com/login?x=1' -H 'Content-Type: application/x-www-form-urlencoded' ` +
+		`--data-binary 'name=bob&password=%5BREDACTED%5D'`
+	if got := rl.Curl(req); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	header := http.Header{"Content-Type": {MIMEJSON}}
+	if got := rl.redactBody(header, []byte(`{"a":{"Token": 123},"secret":null}`)); got != `{"a":{"Token": "[REDACTED]"},"secret":"[REDACTED]"}` {
+		t.Errorf("unexpected redacted body: %s", got)
+	}
+}
@@ -0,0 +1,340 @@
+package httpz
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultLatencyBuckets upper bounds of the latency histogram buckets of MemoryRecorder.
+var DefaultLatencyBuckets = []time.Duration{
+	time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond,
+	50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond, time.Second,
+	2500 * time.Millisecond, 5 * time.Second, 10 * time.Second, 30 * time.Second, time.Minute,
+}
+
+type routeKey struct{}
+
+// RequestMetric is a metric of a request or an attempt of it.
+type RequestMetric struct {
+	// Host host of the request
+	Host string
+	// Route route of the request
+	Route string
+	// Method method of the request
+	Method string
+	// StatusCode status code of the response, 0 if no response
+	StatusCode int
+	// Err error of the request
+	Err error
+	// Latency duration until the response header is received
+	Latency time.Duration
+	// Attempt number of the attempt, or number of attempts made for the whole request
+	Attempt int
+}
+
+// Failed reports whether the request failed, either with an error or a 5xx status code.
+func (m RequestMetric) Failed() bool {
+	return m.Err != nil || m.StatusCode >= 500
+}
+
+// MetricsRecorder receives the metrics of requests. Implementations must be safe for concurrent use.
+type MetricsRecorder interface {
+	// RecordRequest records a request sent through the client, including its retries
+	RecordRequest(m RequestMetric)
+	// RecordAttempt records a single attempt of a request, an attempt greater than 1 is a retry
+	RecordAttempt(m RequestMetric)
+}
+
+// MetricsConfig configures a Metrics.
+type MetricsConfig struct {
+	// Recorder receives the metrics. Default is a new MemoryRecorder
+	Recorder MetricsRecorder
+	// HostFunc returns the host label of the request. Default is the request host
+	HostFunc func(req *http.Request) string
+	// RouteFunc returns the route label of the request.
+	// Default is the path template of requests built by Client.Builder, otherwise the request path
+	RouteFunc func(req *http.Request) string
+}
+
+// Metrics reports the metrics of requests to a MetricsRecorder.
+type Metrics struct {
+	cfg MetricsConfig
+}
+
+// NewMetrics creates a new Metrics.
+func NewMetrics(cfg MetricsConfig) *Metrics {
+	if cfg.Recorder == nil {
+		cfg.Recorder = NewMemoryRecorder(nil)
+	}
+	if cfg.HostFunc == nil {
+		cfg.HostFunc = hostKey
+	}
+	if cfg.RouteFunc == nil {
+		cfg.RouteFunc = requestRoute
+	}
+
+	return &Metrics{cfg: cfg}
+}
+
+// Recorder returns the recorder of the Metrics.
+func (m *Metrics) Recorder() MetricsRecorder {
+	return m.cfg.Recorder
+}
+
+// Middleware returns the metrics middleware, the attempts are recorded from inside the retry loop of the client.
+func (m *Metrics) Middleware() Middleware {
+	return func(next DoFunc) DoFunc {
+		return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			metric := RequestMetric{
+				Host:   m.cfg.HostFunc(req),
+				Route:  m.cfg.RouteFunc(req),
+				Method: req.Method,
+			}
+
+			var attempts int32
+			ctx = withAttemptHook(ctx, func(attempt int, resp *http.Response, err error, cost time.Duration) {
+				atomic.AddInt32(&attempts, 1)
+
+				am := metric
+				am.Attempt = attempt
+				am.Err = err
+				am.Latency = cost
+				if resp != nil {
+					am.StatusCode = resp.StatusCode
+				}
+				m.cfg.Recorder.RecordAttempt(am)
+			})
+
+			begin := time.Now()
+			resp, err := next(ctx, req)
+
+			metric.Latency = time.Since(begin)
+			metric.Attempt = int(atomic.LoadInt32(&attempts))
+			metric.Err = err
+			if resp != nil {
+				metric.StatusCode = resp.StatusCode
+			}
+			m.cfg.Recorder.RecordRequest(metric)
+
+			return resp, err
+		}
+	}
+}
+
+// LatencyHistogram is a histogram of latencies.
+type LatencyHistogram struct {
+	// Bounds upper bounds of the buckets
+	Bounds []time.Duration
+	// Counts counts of the buckets, the last one counts latencies greater than all bounds
+	Counts []uint64
+	// Count number of latencies
+	Count uint64
+	// Sum sum of latencies
+	Sum time.Duration
+	// Min minimum latency
+	Min time.Duration
+	// Max maximum latency
+	Max time.Duration
+}
+
+func newLatencyHistogram(bounds []time.Duration) LatencyHistogram {
+	return LatencyHistogram{
+		Bounds: bounds,
+		Counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+func (h *LatencyHistogram) observe(d time.Duration) {
+	i := sort.Search(len(h.Bounds), func(i int) bool { return d <= h.Bounds[i] })
+	h.Counts[i]++
+	if h.Count == 0 || d < h.Min {
+		h.Min = d
+	}
+	if d > h.Max {
+		h.Max = d
+	}
+	h.Count++
+	h.Sum += d
+}
+
+func (h LatencyHistogram) clone() LatencyHistogram {
+	h.Counts = append([]uint64(nil), h.Counts...)
+	return h
+}
+
+// Mean returns the mean latency.
+func (h LatencyHistogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Percentile returns the estimated p percentile latency, e.g. 0.99, interpolated inside the bucket.
+func (h LatencyHistogram) Percentile(p float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	if p <= 0 {
+		return h.Min
+	}
+	if p >= 1 {
+		return h.Max
+	}
+
+	rank := p * float64(h.Count)
+	var cumulative float64
+	for i, n := range h.Counts {
+		if n == 0 {
+			continue
+		}
+
+		if cumulative+float64(n) >= rank {
+			lower := h.Min
+			if i > 0 && h.Bounds[i-1] > lower {
+				lower = h.Bounds[i-1]
+			}
+			upper := h.Max
+			if i < len(h.Bounds) && h.Bounds[i] < upper {
+				upper = h.Bounds[i]
+			}
+
+			d := lower + time.Duration(float64(upper-lower)*(rank-cumulative)/float64(n))
+			return d
+		}
+		cumulative += float64(n)
+	}
+
+	return h.Max
+}
+
+// RouteMetrics metrics of a route in a MetricsSnapshot.
+type RouteMetrics struct {
+	// Host host label of the route
+	Host string
+	// Route route label
+	Route string
+	// Requests number of requests
+	Requests uint64
+	// Errors number of failed requests, see RequestMetric.Failed
+	Errors uint64
+	// Attempts number of attempts
+	Attempts uint64
+	// Retries number of retry attempts
+	Retries uint64
+	// StatusCodes number of requests per status code, 0 for requests without a response
+	StatusCodes map[int]uint64
+	// Latency latency histogram of requests
+	Latency LatencyHistogram
+	// AttemptLatency latency histogram of attempts
+	AttemptLatency LatencyHistogram
+}
+
+// MemoryRecorder is an in-memory MetricsRecorder aggregating metrics per host and route.
+type MemoryRecorder struct {
+	mu      sync.Mutex
+	buckets []time.Duration
+	routes  map[[2]string]*RouteMetrics
+}
+
+// NewMemoryRecorder creates a new MemoryRecorder with the latency histogram bucket bounds.
+// if buckets is empty, it defaults to DefaultLatencyBuckets.
+func NewMemoryRecorder(buckets []time.Duration) *MemoryRecorder {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+
+	buckets = append([]time.Duration(nil), buckets...)
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i] < buckets[j] })
+
+	return &MemoryRecorder{
+		buckets: buckets,
+		routes:  make(map[[2]string]*RouteMetrics),
+	}
+}
+
+func (r *MemoryRecorder) RecordRequest(m RequestMetric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rm := r.route(m)
+	rm.Requests++
+	if m.Failed() {
+		rm.Errors++
+	}
+	rm.StatusCodes[m.StatusCode]++
+	rm.Latency.observe(m.Latency)
+}
+
+func (r *MemoryRecorder) RecordAttempt(m RequestMetric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rm := r.route(m)
+	rm.Attempts++
+	if m.Attempt > 1 {
+		rm.Retries++
+	}
+	rm.AttemptLatency.observe(m.Latency)
+}
+
+// Snapshot returns a copy of the metrics sorted by host and route.
+func (r *MemoryRecorder) Snapshot() []RouteMetrics {
+	r.mu.Lock()
+	snapshot := make([]RouteMetrics, 0, len(r.routes))
+	for _, rm := range r.routes {
+		c := *rm
+		c.StatusCodes = make(map[int]uint64, len(rm.StatusCodes))
+		for code, n := range rm.StatusCodes {
+			c.StatusCodes[code] = n
+		}
+		c.Latency = rm.Latency.clone()
+		c.AttemptLatency = rm.AttemptLatency.clone()
+		snapshot = append(snapshot, c)
+	}
+	r.mu.Unlock()
+
+	sort.Slice(snapshot, func(i, j int) bool {
+		if snapshot[i].Host != snapshot[j].Host {
+			return snapshot[i].Host < snapshot[j].Host
+		}
+		return snapshot[i].Route < snapshot[j].Route
+	})
+	return snapshot
+}
+
+// Reset removes all metrics.
+func (r *MemoryRecorder) Reset() {
+	r.mu.Lock()
+	r.routes = make(map[[2]string]*RouteMetrics)
+	r.mu.Unlock()
+}
+
+// route returns the metrics of the route of m, the caller must hold the lock.
+func (r *MemoryRecorder) route(m RequestMetric) *RouteMetrics {
+	key := [2]string{m.Host, m.Route}
+	rm, ok := r.routes[key]
+	if !ok {
+		rm = &RouteMetrics{
+			Host:           m.Host,
+			Route:          m.Route,
+			StatusCodes:    make(map[int]uint64),
+			Latency:        newLatencyHistogram(r.buckets),
+			AttemptLatency: newLatencyHistogram(r.buckets),
+		}
+		r.routes[key] = rm
+	}
+	return rm
+}
+
+// requestRoute returns the path template of requests built by Client.Builder, otherwise the request path.
+func requestRoute(req *http.Request) string {
+	if route, ok := req.Context().Value(routeKey{}).(string); ok {
+		return route
+	}
+	return req.URL.Path
+}
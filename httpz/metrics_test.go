@@ -0,0 +1,110 @@
+package httpz
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/users/") && atomic.AddInt32(&calls, 1)%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics(MetricsConfig{})
+	client := NewClient(
+		WithBaseURL(server.URL),
+		WithMiddleware(metrics.Middleware()),
+		WithRetryPolicy(RetryPolicy{MaxRetries: 1}),
+	)
+
+	for _, id := range []string{"1", "2"} {
+		resp, err := client.Builder(http.MethodGet, "/users/{id}").PathParam("id", id).Do()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	resp, err := client.Builder(http.MethodGet, "/health").NoRetry().Do()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	snapshot := metrics.Recorder().(*MemoryRecorder).Snapshot()
+	if len(snapshot) != 2 {
+		t.Fatalf("expected 2 routes, got %+v", snapshot)
+	}
+
+	health, users := snapshot[0], snapshot[1]
+	if health.Route != "/health" || health.Requests != 1 || health.Attempts != 1 || health.Retries != 0 {
+		t.Errorf("unexpected health metrics: %+v", health)
+	}
+	if users.Route != "/users/{id}" || users.Requests != 2 || users.Attempts != 4 || users.Retries != 2 ||
+		users.Errors != 0 || users.StatusCodes[http.StatusOK] != 2 {
+		t.Errorf("unexpected users metrics: %+v", users)
+	}
+	if users.Latency.Count != 2 || users.AttemptLatency.Count != 4 || users.Latency.Percentile(0.5) <= 0 {
+		t.Errorf("unexpected latency: %+v", users.Latency)
+	}
+
+	_, err = client.Builder(http.MethodGet, "http://127.0.0.1:1/down").NoRetry().Do()
+	if err == nil {
+		t.Fatal("expected connection error")
+	}
+
+	snapshot = metrics.Recorder().(*MemoryRecorder).Snapshot()
+	if down := snapshot[0]; down.Host != "127.0.0.1:1" || down.Errors != 1 || down.StatusCodes[0] != 1 {
+		t.Errorf("unexpected down metrics: %+v", down)
+	}
+
+	metrics.Recorder().(*MemoryRecorder).Reset()
+	if len(metrics.Recorder().(*MemoryRecorder).Snapshot()) != 0 {
+		t.Error("expected empty snapshot after reset")
+	}
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	h := newLatencyHistogram([]time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 50 * time.Millisecond})
+	if h.Percentile(0.5) != 0 || h.Mean() != 0 {
+		t.Error("expected 0 for empty histogram")
+	}
+
+	for i := 1; i <= 100; i++ {
+		h.observe(time.Duration(i) * time.Millisecond)
+	}
+
+	if h.Count != 100 || h.Min != time.Millisecond || h.Max != 100*time.Millisecond {
+		t.Errorf("unexpected histogram: %+v", h)
+	}
+	if h.Mean() != 50500*time.Microsecond {
+		t.Errorf("unexpected mean: %v", h.Mean())
+	}
+
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0, time.Millisecond},
+		{0.05, 5500 * time.Microsecond},
+		{0.15, 15 * time.Millisecond},
+		{0.5, 50 * time.Millisecond},
+		{0.9, 90 * time.Millisecond},
+		{1, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := h.Percentile(tt.p); got != tt.want {
+			t.Errorf("p%v: expected %v, got %v", tt.p, tt.want, got)
+		}
+	}
+}
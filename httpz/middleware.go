@@ -3,8 +3,33 @@ package httpz
 import (
 	"context"
 	"net/http"
+	"time"
 )
 
 type DoFunc func(ctx context.Context, req *http.Request) (*http.Response, error)
 
 type Middleware func(DoFunc) DoFunc
+
+type attemptHookKey struct{}
+
+// attemptHook is called by the client after each attempt of a request, including retries.
+type attemptHook func(attempt int, resp *http.Response, err error, cost time.Duration)
+
+// withAttemptHook returns a ctx carrying the hook, the hooks already carried by ctx are called first.
+func withAttemptHook(ctx context.Context, hook attemptHook) context.Context {
+	if prev, ok := ctx.Value(attemptHookKey{}).(attemptHook); ok {
+		next := hook
+		hook = func(attempt int, resp *http.Response, err error, cost time.Duration) {
+			prev(attempt, resp, err, cost)
+			next(attempt, resp, err, cost)
+		}
+	}
+	return context.WithValue(ctx, attemptHookKey{}, hook)
+}
+
+// notifyAttempt calls the hooks carried by ctx.
+func notifyAttempt(ctx context.Context, attempt int, resp *http.Response, err error, cost time.Duration) {
+	if hook, ok := ctx.Value(attemptHookKey{}).(attemptHook); ok {
+		hook(attempt, resp, err, cost)
+	}
+}
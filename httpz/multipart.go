@@ -0,0 +1,237 @@
+package httpz
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PartOpener opens the content of a multipart part, it is called again each time the body is rebuilt.
+type PartOpener func() (io.ReadCloser, error)
+
+// ProgressFunc reports the bytes written of a multipart body or a download, total is -1 if the size is unknown.
+type ProgressFunc func(written, total int64)
+
+// Multipart builds a streaming multipart/form-data request body.
+// The parts are written through an io.Pipe while the request is being sent, so large files are not buffered.
+type Multipart struct {
+	boundary string
+	parts    []multipartPart
+	progress ProgressFunc
+	err      error
+}
+
+type multipartPart struct {
+	header textproto.MIMEHeader
+	value  []byte
+	open   PartOpener
+	size   int64
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// NewMultipart creates a new Multipart with a random boundary.
+func NewMultipart() *Multipart {
+	return &Multipart{
+		boundary: multipart.NewWriter(io.Discard).Boundary(),
+	}
+}
+
+// SetBoundary overrides the random boundary.
+func (m *Multipart) SetBoundary(boundary string) *Multipart {
+	if err := multipart.NewWriter(io.Discard).SetBoundary(boundary); err != nil {
+		m.setErr(err)
+		return m
+	}
+
+	m.boundary = boundary
+	return m
+}
+
+// Boundary returns the boundary of the body.
+func (m *Multipart) Boundary() string {
+	return m.boundary
+}
+
+// ContentType returns the Content-Type of the body with the boundary.
+func (m *Multipart) ContentType() string {
+	return "multipart/form-data; boundary=" + m.boundary
+}
+
+// OnProgress sets the progress callback, it is called from the goroutine writing the body.
+func (m *Multipart) OnProgress(fn ProgressFunc) *Multipart {
+	m.progress = fn
+	return m
+}
+
+// AddField adds a form field.
+func (m *Multipart) AddField(name, value string) *Multipart {
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, quoteEscaper.Replace(name)))
+	m.parts = append(m.parts, multipartPart{header: header, value: []byte(value), size: int64(len(value))})
+	return m
+}
+
+// AddFile adds a file part read from path, the file is opened when the body is written.
+// if filename is empty, the base name of path is used.
+func (m *Multipart) AddFile(field, filename, path string) *Multipart {
+	info, err := os.Stat(path)
+	if err != nil {
+		m.setErr(err)
+		return m
+	}
+
+	if filename == "" {
+		filename = filepath.Base(path)
+	}
+
+	return m.AddReader(field, filename, info.Size(), func() (io.ReadCloser, error) {
+		return os.Open(path)
+	})
+}
+
+// AddReader adds a file part with the content type application/octet-stream.
+// size is the content size, -1 if unknown.
+func (m *Multipart) AddReader(field, filename string, size int64, open PartOpener) *Multipart {
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		quoteEscaper.Replace(field), quoteEscaper.Replace(filename)))
+	header.Set("Content-Type", "application/octet-stream")
+	return m.AddPart(header, size, open)
+}
+
+// AddPart adds a part with custom headers, e.g. Content-Disposition, Content-Type.
+// size is the content size, -1 if unknown.
+func (m *Multipart) AddPart(header textproto.MIMEHeader, size int64, open PartOpener) *Multipart {
+	if open == nil {
+		m.setErr(fmt.Errorf("httpz: multipart part opener is nil"))
+		return m
+	}
+
+	m.parts = append(m.parts, multipartPart{header: header, open: open, size: size})
+	return m
+}
+
+// Size returns the size of the whole body, -1 if any part size is unknown.
+func (m *Multipart) Size() int64 {
+	var size int64
+	var counter countWriter
+	w := multipart.NewWriter(&counter)
+	_ = w.SetBoundary(m.boundary)
+	for _, p := range m.parts {
+		if p.size < 0 {
+			return -1
+		}
+		size += p.size
+		_, _ = w.CreatePart(p.header)
+	}
+	_ = w.Close()
+
+	return size + counter.n
+}
+
+// Body returns a new reader of the body, the parts are written into it by a goroutine started on the first Read.
+// Closing the reader stops the writing, no part is opened if the reader is closed before being read.
+func (m *Multipart) Body() (io.ReadCloser, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	return &lazyPipe{produce: m.write}, nil
+}
+
+// NewRequest creates a request with the body, the GetBody of the request reopens all parts.
+func (m *Multipart) NewRequest(ctx context.Context, method, url string) (*http.Request, error) {
+	body, err := m.Body()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		_ = body.Close()
+		return nil, err
+	}
+
+	req.GetBody = m.Body
+	req.Header.Set("Content-Type", m.ContentType())
+	if size := m.Size(); size >= 0 {
+		req.ContentLength = size
+	}
+
+	return req, nil
+}
+
+func (m *Multipart) write(out io.Writer) error {
+	if m.progress != nil {
+		out = &progressWriter{w: out, total: m.Size(), fn: m.progress}
+	}
+
+	w := multipart.NewWriter(out)
+	_ = w.SetBoundary(m.boundary)
+
+	for _, p := range m.parts {
+		part, err := w.CreatePart(p.header)
+		if err != nil {
+			return err
+		}
+
+		if p.open == nil {
+			if _, err = io.Copy(part, bytes.NewReader(p.value)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		src, err := p.open()
+		if err != nil {
+			return fmt.Errorf("httpz: open multipart part failed: %w", err)
+		}
+
+		_, err = io.Copy(part, src)
+		_ = src.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return w.Close()
+}
+
+func (m *Multipart) setErr(err error) {
+	if m.err == nil {
+		m.err = err
+	}
+}
+
+type countWriter struct {
+	n int64
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+type progressWriter struct {
+	w       io.Writer
+	written int64
+	total   int64
+	fn      ProgressFunc
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	if n > 0 {
+		w.written += int64(n)
+		w.fn(w.written, w.total)
+	}
+	return n, err
+}
@@ -0,0 +1,204 @@
+package httpz
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMultipart(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte(strings.Repeat("a", 100<<10)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			_, _ = io.Copy(io.Discard, r.Body)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("parse multipart form failed: %v", err)
+			return
+		}
+
+		if r.FormValue("name") != "bob" {
+			t.Errorf("unexpected field: %s", r.FormValue("name"))
+		}
+
+		f, fh, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+		b, _ := io.ReadAll(f)
+		_ = f.Close()
+		if fh.Filename != "a.txt" || len(b) != 100<<10 {
+			t.Errorf("unexpected file: %s %d", fh.Filename, len(b))
+		}
+
+		meta := r.MultipartForm.File["meta"]
+		if len(meta) != 1 || meta[0].Header.Get("Content-Type") != "application/json" ||
+			meta[0].Header.Get("X-Part") != "1" {
+			t.Errorf("unexpected part header: %v", meta)
+		}
+
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="meta"; filename="meta.json"`)
+	header.Set("Content-Type", "application/json")
+	header.Set("X-Part", "1")
+
+	var written, total int64
+	body := NewMultipart().
+		AddField("name", "bob").
+		AddFile("file", "", path).
+		AddPart(header, -1, func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(`{"a":1}`)), nil
+		}).
+		OnProgress(func(w, t int64) {
+			atomic.StoreInt64(&written, w)
+			atomic.StoreInt64(&total, t)
+		})
+
+	if body.Size() != -1 {
+		t.Errorf("expected unknown size, got %d", body.Size())
+	}
+
+	client := NewClient(WithRetryPolicy(RetryPolicy{MaxRetries: 1, RetryNonIdempotent: true}))
+	out, err := Post[string](context.Background(), client, server.URL, nil, body, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "ok" || atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("unexpected result: %s, calls %d", out, calls)
+	}
+	if atomic.LoadInt64(&total) != -1 || atomic.LoadInt64(&written) <= 100<<10 {
+		t.Errorf("unexpected progress: %d/%d", written, total)
+	}
+}
+
+func TestMultipart_Size(t *testing.T) {
+	var written, total int64
+	body := NewMultipart().
+		SetBoundary("httpz-boundary").
+		AddField("name", "bob").
+		AddReader("file", "b.bin", 3, func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("abc")), nil
+		}).
+		OnProgress(func(w, t int64) {
+			written, total = w, t
+		})
+
+	req, err := body.NewRequest(context.Background(), http.MethodPost, "/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Header.Get("Content-Type") != "multipart/form-data; boundary=httpz-boundary" {
+		t.Errorf("unexpected Content-Type: %s", req.Header.Get("Content-Type"))
+	}
+
+	for i := 0; i < 2; i++ {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if int64(len(b)) != req.ContentLength || written != total || total != req.ContentLength {
+			t.Errorf("unexpected size: %d, content length %d, progress %d/%d", len(b), req.ContentLength, written, total)
+		}
+		if !strings.Contains(string(b), `filename="b.bin"`) || !strings.HasSuffix(string(b), "--httpz-boundary--\r\n") {
+			t.Errorf("unexpected body: %s", b)
+		}
+
+		req.Body, _ = req.GetBody()
+	}
+}
+
+func TestMultipart_Error(t *testing.T) {
+	_, err := NewMultipart().AddFile("file", "", "/not/exist").NewRequest(context.Background(), http.MethodPost, "/")
+	if err == nil {
+		t.Error("expected error on missing file")
+	}
+
+	if _, err = NewMultipart().SetBoundary("bad boundary\n").Body(); err == nil {
+		t.Error("expected error on invalid boundary")
+	}
+
+	body, _ := NewMultipart().AddReader("file", "f", -1, func() (io.ReadCloser, error) {
+		return nil, os.ErrNotExist
+	}).Body()
+	if _, err = io.ReadAll(body); err == nil {
+		t.Error("expected error on open failure")
+	}
+}
+
+func TestMultipart_Lazy(t *testing.T) {
+	var opened, closed int32
+	m := NewMultipart().AddReader("file", "f", 4, func() (io.ReadCloser, error) {
+		atomic.AddInt32(&opened, 1)
+		return &closeFunc{Reader: strings.NewReader("data"), fn: func() { atomic.AddInt32(&closed, 1) }}, nil
+	})
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		_, _ = m.Body()
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("expected no goroutine of unread bodies, got %d before and %d after", before, after)
+	}
+
+	body, _ := m.Body()
+	if err := body.Close(); err != nil || atomic.LoadInt32(&opened) != 0 {
+		t.Fatalf("expected no part to be opened of an unread body, got %d %v", opened, err)
+	}
+	if _, err := body.Read(make([]byte, 1)); err != io.ErrClosedPipe {
+		t.Errorf("expected io.ErrClosedPipe, got %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	req, _ := m.NewRequest(ctx, http.MethodPut, server.URL)
+	client := NewClient(WithRetryPolicy(RetryPolicy{MaxRetries: 3, MinRetryDelay: 10 * time.Second}))
+	if _, err := client.Do(req); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	if o, c := atomic.LoadInt32(&opened), atomic.LoadInt32(&closed); o != 1 || c != 1 {
+		t.Errorf("expected only the sent body to open its part, got %d opened %d closed", o, c)
+	}
+}
+
+type closeFunc struct {
+	io.Reader
+	fn func()
+}
+
+func (c *closeFunc) Close() error {
+	c.fn()
+	return nil
+}
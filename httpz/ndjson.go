@@ -0,0 +1,66 @@
+package httpz
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+)
+
+// NDJSONDecoder decodes newline-delimited JSON values from a reader, blank lines are skipped.
+type NDJSONDecoder[T any] struct {
+	ctx     context.Context
+	scanner *bufio.Scanner
+	codec   Codec
+	line    int
+}
+
+// NewNDJSONDecoder creates a new NDJSONDecoder, the lines are decoded by JSONCodec.
+// Decode returns the context error once the ctx is done, the reader should be closed by the ctx,
+// e.g. a response body of a request with the ctx, to stop a blocked read.
+func NewNDJSONDecoder[T any](ctx context.Context, r io.Reader) *NDJSONDecoder[T] {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), defaultSSEMaxLineSize)
+	return &NDJSONDecoder[T]{
+		ctx:     ctx,
+		scanner: scanner,
+		codec:   JSONCodec{},
+	}
+}
+
+// Buffer sets the maximum size of a line. Default is 1MB. It must be called before the first Decode.
+func (d *NDJSONDecoder[T]) Buffer(maxLineSize int) {
+	d.scanner.Buffer(make([]byte, 0, 4096), maxLineSize)
+}
+
+// Decode returns the next value, io.EOF is returned at the end of the stream.
+func (d *NDJSONDecoder[T]) Decode() (T, error) {
+	var v T
+	for {
+		if err := d.ctx.Err(); err != nil {
+			return v, err
+		}
+
+		if !d.scanner.Scan() {
+			if err := d.ctx.Err(); err != nil {
+				return v, err
+			}
+			if err := d.scanner.Err(); err != nil {
+				return v, err
+			}
+			return v, io.EOF
+		}
+
+		d.line++
+		line := bytes.TrimSpace(d.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		if err := d.codec.Unmarshal(line, &v); err != nil {
+			return v, fmt.Errorf("httpz: decode ndjson line %d failed: %w", d.line, err)
+		}
+		return v, nil
+	}
+}
@@ -0,0 +1,38 @@
+package httpz
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestNDJSONDecoder(t *testing.T) {
+	d := NewNDJSONDecoder[typedUser](context.Background(),
+		strings.NewReader("{\"id\":1,\"name\":\"a\"}\n\n  \r\n{\"id\":2}\r\n{bad}\n"))
+
+	for i := 1; i <= 2; i++ {
+		u, err := d.Decode()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if u.ID != i {
+			t.Errorf("expected id %d, got %d", i, u.ID)
+		}
+	}
+
+	if _, err := d.Decode(); err == nil || !strings.Contains(err.Error(), "line 5") {
+		t.Errorf("expected decode error of line 5, got %v", err)
+	}
+	if _, err := d.Decode(); !errors.Is(err, io.EOF) {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d = NewNDJSONDecoder[typedUser](ctx, strings.NewReader("{\"id\":1}\n"))
+	if _, err := d.Decode(); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
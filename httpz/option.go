@@ -16,8 +16,29 @@ type RetryPolicy struct {
 	MaxRetries int
 	// MinRetryDelay minimum retry delay. The retry delay will grow exponentially. Default is 0ms
 	MinRetryDelay time.Duration
+	// MaxRetryDelay maximum retry delay, including the delay asked by Retry-After. Default is 0, means no limit
+	MaxRetryDelay time.Duration
+	// Backoff computes the retry delay. Default is ExponentialBackoff
+	Backoff BackoffFunc
+	// IgnoreRetryAfter ignores the Retry-After header of 429 and 503 responses. Default is false
+	IgnoreRetryAfter bool
+	// AttemptTimeout timeout of each attempt, including reading the response body. Default is 0, means no limit
+	AttemptTimeout time.Duration
+	// TotalTimeout deadline budget of all attempts and retry delays. Default is 0, means no limit.
+	// No new attempt will start if the remaining budget cannot cover the retry delay and AttemptTimeout
+	TotalTimeout time.Duration
+	// Budget limits retries of all requests sharing it. Default is nil, means no limit
+	Budget *RetryBudget
 	// ShouldRetry retry judgment function. Default is DefaultRetryableFunc
 	ShouldRetry RetryableFunc
+	// RetryNonIdempotent retries the requests of non-idempotent methods, e.g. POST and PATCH, even without
+	// the Idempotency-Key header. Default is false, means only the requests of idempotent methods and
+	// the requests with the Idempotency-Key header are retried
+	RetryNonIdempotent bool
+	// IdempotencyKey generates the Idempotency-Key header of the non-idempotent requests without it,
+	// the key is generated once per request and reused by all retries. Default is nil, means no key is generated,
+	// NewIdempotencyKey can be used
+	IdempotencyKey func(req *http.Request) string
 }
 
 func WithHttpClient(hc *http.Client) Option {
@@ -36,9 +57,33 @@ func WithRetryPolicy(policy RetryPolicy) Option {
 		if policy.MinRetryDelay > 0 {
 			c.retryPolicy.MinRetryDelay = policy.MinRetryDelay
 		}
+		if policy.MaxRetryDelay > 0 {
+			c.retryPolicy.MaxRetryDelay = policy.MaxRetryDelay
+		}
+		if policy.Backoff != nil {
+			c.retryPolicy.Backoff = policy.Backoff
+		}
+		if policy.IgnoreRetryAfter {
+			c.retryPolicy.IgnoreRetryAfter = true
+		}
+		if policy.AttemptTimeout > 0 {
+			c.retryPolicy.AttemptTimeout = policy.AttemptTimeout
+		}
+		if policy.TotalTimeout > 0 {
+			c.retryPolicy.TotalTimeout = policy.TotalTimeout
+		}
+		if policy.Budget != nil {
+			c.retryPolicy.Budget = policy.Budget
+		}
 		if policy.ShouldRetry != nil {
 			c.retryPolicy.ShouldRetry = policy.ShouldRetry
 		}
+		if policy.RetryNonIdempotent {
+			c.retryPolicy.RetryNonIdempotent = true
+		}
+		if policy.IdempotencyKey != nil {
+			c.retryPolicy.IdempotencyKey = policy.IdempotencyKey
+		}
 	}
 }
 
@@ -48,8 +93,21 @@ func WithMiddleware(mws ...Middleware) Option {
 	}
 }
 
+// DefaultFailureFunc is the default failure judgment of CircuitBreaker, EndpointPool and Hedger.
+// It is DefaultRetryableFunc except 429, a rate limited response does not mean the upstream is unhealthy.
+func DefaultFailureFunc(resp *http.Response, err error) bool {
+	if err == nil && resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+		return false
+	}
+	return DefaultRetryableFunc(resp, err)
+}
+
 func DefaultRetryableFunc(resp *http.Response, err error) bool {
 	if err != nil {
+		if errors.Is(err, ErrAttemptTimeout) {
+			return true
+		}
+
 		if errors.Is(err, context.Canceled) {
 			return false
 		}
@@ -61,7 +119,7 @@ func DefaultRetryableFunc(resp *http.Response, err error) bool {
 		return true
 	}
 
-	if resp.StatusCode >= 500 {
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
 		return true
 	}
 
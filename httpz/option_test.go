@@ -191,6 +191,31 @@ func TestWithMiddleware(t *testing.T) {
 	})
 }
 
+func TestDefaultFailureFunc(t *testing.T) {
+	tests := []struct {
+		status int
+		want   bool
+	}{
+		{http.StatusOK, false},
+		{http.StatusTooManyRequests, false},
+		{http.StatusInternalServerError, true},
+		{http.StatusServiceUnavailable, true},
+	}
+
+	for _, tt := range tests {
+		if got := DefaultFailureFunc(&http.Response{StatusCode: tt.status}, nil); got != tt.want {
+			t.Errorf("expected %v of status %d, got %v", tt.want, tt.status, got)
+		}
+	}
+
+	if !DefaultFailureFunc(nil, errors.New("connection error")) || DefaultFailureFunc(nil, context.Canceled) {
+		t.Error("unexpected failure judgment of errors")
+	}
+	if !DefaultRetryableFunc(&http.Response{StatusCode: http.StatusTooManyRequests}, nil) {
+		t.Error("expected 429 to be retried")
+	}
+}
+
 func TestDefaultRetryableFunc(t *testing.T) {
 	t.Run("retry on generic error", func(t *testing.T) {
 		result := DefaultRetryableFunc(nil, errors.New("connection error"))
@@ -0,0 +1,249 @@
+package httpz
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const defaultMaxPages = 1000
+
+// ErrMaxPages reports that the pagination stopped at the max pages safeguard.
+var ErrMaxPages = errors.New("httpz: max pages reached")
+
+// NextPageFunc returns the request of the next page from the request and response of the current page,
+// nil means there is no next page. n is the number of items of the current page.
+type NextPageFunc func(req *http.Request, resp *http.Response, body []byte, n int) (*http.Request, error)
+
+// PageConfig configures a Pager.
+type PageConfig[T any] struct {
+	// Codec decodes the pages as []T if Items is nil. Default is JSONCodec
+	Codec Codec
+	// Items extracts the items of a page, e.g. from a wrapper object
+	Items func(resp *http.Response, body []byte) ([]T, error)
+	// Next returns the request of the next page. Default is LinkNext
+	Next NextPageFunc
+	// MaxPages maximum number of pages to fetch, ErrMaxPages is returned if more pages remain. Default is 1000
+	MaxPages int
+}
+
+// Pager fetches the pages of a paginated api through a Client.
+type Pager[T any] struct {
+	client *Client
+	req    *http.Request
+	cfg    PageConfig[T]
+	pages  int
+}
+
+// NewPager creates a new Pager, req is the request of the first page.
+// The requests of the next pages are copies of req with their bodies rebuilt through req.GetBody.
+func NewPager[T any](c *Client, req *http.Request, cfg PageConfig[T]) *Pager[T] {
+	if cfg.Codec == nil {
+		cfg.Codec = JSONCodec{}
+	}
+	if cfg.Next == nil {
+		cfg.Next = LinkNext()
+	}
+	if cfg.MaxPages <= 0 {
+		cfg.MaxPages = defaultMaxPages
+	}
+
+	return &Pager[T]{client: c, req: req, cfg: cfg}
+}
+
+// Pages returns the number of pages fetched.
+func (p *Pager[T]) Pages() int {
+	return p.pages
+}
+
+// NextPage fetches and returns the items of the next page, io.EOF is returned if there are no more pages.
+// Non-2xx responses are returned as *HTTPError.
+func (p *Pager[T]) NextPage() ([]T, error) {
+	if p.req == nil {
+		return nil, io.EOF
+	}
+	if p.pages >= p.cfg.MaxPages {
+		return nil, ErrMaxPages
+	}
+
+	req := p.req
+	if p.pages > 0 {
+		// the first request is sent as it is
+		if err := rebuildBody(req); err != nil {
+			p.req = nil
+			return nil, err
+		}
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		p.req = nil
+		return nil, fmt.Errorf("send http request failed: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		p.req = nil
+		return nil, newHTTPError(resp, p.cfg.Codec)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		p.req = nil
+		return nil, fmt.Errorf("read http response body failed: %w", err)
+	}
+	p.pages++
+
+	var items []T
+	if p.cfg.Items != nil {
+		items, err = p.cfg.Items(resp, body)
+	} else if len(body) > 0 {
+		err = unmarshal(p.cfg.Codec, resp.Header.Get("Content-Type"), body, &items)
+	}
+	if err != nil {
+		p.req = nil
+		return nil, fmt.Errorf("decode page %d failed: %w", p.pages, err)
+	}
+
+	p.req, err = p.cfg.Next(req, resp, body, len(items))
+	if err != nil {
+		p.req = nil
+		return items, err
+	}
+
+	return items, nil
+}
+
+// sensitiveLinkHeaders are removed from the request of a next link to another host.
+var sensitiveLinkHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Www-Authenticate"}
+
+// LinkNext returns the NextPageFunc following the RFC 5988 Link header with rel="next".
+// Authorization, Proxy-Authorization and Cookie headers are not sent to a next link of another host.
+func LinkNext() NextPageFunc {
+	return func(req *http.Request, resp *http.Response, body []byte, n int) (*http.Request, error) {
+		link := linkNext(resp.Header.Values("Link"))
+		if link == "" {
+			return nil, nil
+		}
+
+		u, err := req.URL.Parse(link)
+		if err != nil {
+			return nil, fmt.Errorf("httpz: invalid next link %q: %w", link, err)
+		}
+
+		next := req.Clone(req.Context())
+		next.URL = u
+		next.Host = ""
+		// the credentials are not sent to another host, like the redirects of net/http
+		if !strings.EqualFold(u.Host, req.URL.Host) {
+			for _, h := range sensitiveLinkHeaders {
+				next.Header.Del(h)
+			}
+		}
+		return next, nil
+	}
+}
+
+// CursorNext returns the NextPageFunc setting the query parameter param to the cursor extracted from
+// the page body, an empty cursor means there is no next page.
+func CursorNext(param string, cursor func(body []byte) (string, error)) NextPageFunc {
+	return func(req *http.Request, resp *http.Response, body []byte, n int) (*http.Request, error) {
+		c, err := cursor(body)
+		if err != nil || c == "" {
+			return nil, err
+		}
+		return withQuery(req, param, c), nil
+	}
+}
+
+// PageNumberNext returns the NextPageFunc incrementing the page number query parameter param,
+// the page number of a request without param is 1. The pagination stops at an empty page,
+// or a page with less than pageSize items if pageSize is greater than 0.
+func PageNumberNext(param string, pageSize int) NextPageFunc {
+	return func(req *http.Request, resp *http.Response, body []byte, n int) (*http.Request, error) {
+		if n == 0 || (pageSize > 0 && n < pageSize) {
+			return nil, nil
+		}
+
+		page := 1
+		if v := req.URL.Query().Get(param); v != "" {
+			var err error
+			if page, err = strconv.Atoi(v); err != nil {
+				return nil, fmt.Errorf("httpz: invalid page number %q: %w", v, err)
+			}
+		}
+		return withQuery(req, param, strconv.Itoa(page+1)), nil
+	}
+}
+
+// OffsetNext returns the NextPageFunc advancing the offset query parameter param by the items of the page,
+// the offset of a request without param is 0. The pagination stops at an empty page,
+// or a page with less than pageSize items if pageSize is greater than 0.
+func OffsetNext(param string, pageSize int) NextPageFunc {
+	return func(req *http.Request, resp *http.Response, body []byte, n int) (*http.Request, error) {
+		if n == 0 || (pageSize > 0 && n < pageSize) {
+			return nil, nil
+		}
+
+		offset := 0
+		if v := req.URL.Query().Get(param); v != "" {
+			var err error
+			if offset, err = strconv.Atoi(v); err != nil {
+				return nil, fmt.Errorf("httpz: invalid offset %q: %w", v, err)
+			}
+		}
+		return withQuery(req, param, strconv.Itoa(offset+n)), nil
+	}
+}
+
+// withQuery returns a copy of the request with the query parameter set.
+func withQuery(req *http.Request, key, value string) *http.Request {
+	next := req.Clone(req.Context())
+	query := next.URL.Query()
+	query.Set(key, value)
+	next.URL.RawQuery = query.Encode()
+	return next
+}
+
+// linkNext returns the target of the link with rel="next" of the Link header values.
+func linkNext(values []string) string {
+	for _, v := range values {
+		for {
+			start := strings.IndexByte(v, '<')
+			if start < 0 {
+				break
+			}
+			end := strings.IndexByte(v[start:], '>')
+			if end < 0 {
+				break
+			}
+			end += start
+
+			target := v[start+1 : end]
+			params := v[end+1:]
+			v = ""
+			if i := strings.IndexByte(params, '<'); i >= 0 {
+				params, v = params[:i], params[i:]
+			}
+
+			for _, param := range strings.Split(params, ";") {
+				key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || !strings.EqualFold(strings.TrimSpace(key), "rel") {
+					continue
+				}
+
+				value = strings.Trim(strings.TrimSpace(strings.TrimRight(strings.TrimSpace(value), ",")), `"`)
+				for _, rel := range strings.Fields(value) {
+					if strings.EqualFold(rel, "next") {
+						return target
+					}
+				}
+			}
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,41 @@
+//go:build go1.23
+
+package httpz
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"net/http"
+)
+
+// All returns an iterator over the items of the remaining pages, the next page is fetched once the items
+// of the current page are consumed. The iteration stops after yielding an error, ErrMaxPages is yielded
+// if more pages remain after MaxPages pages.
+func (p *Pager[T]) All() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		for {
+			items, err := p.NextPage()
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					yield(zero, err)
+				}
+				return
+			}
+		}
+	}
+}
+
+// Paginate sends the request of the first page through the client with the ctx, and returns an iterator
+// over the items of all pages, see Pager.All.
+func Paginate[T any](ctx context.Context, c *Client, req *http.Request, cfg PageConfig[T]) iter.Seq2[T, error] {
+	return NewPager[T](c, req.WithContext(ctx), cfg).All()
+}
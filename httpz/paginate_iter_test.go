@@ -0,0 +1,91 @@
+//go:build go1.23
+
+package httpz
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		w.Header().Set("Content-Type", MIMEJSON)
+		if offset >= 6 {
+			_, _ = w.Write([]byte(`[]`))
+			return
+		}
+		_, _ = w.Write([]byte(`[{"id":` + strconv.Itoa(offset+1) + `},{"id":` + strconv.Itoa(offset+2) + `}]`))
+	}))
+	defer server.Close()
+
+	cfg := PageConfig[typedUser]{Next: OffsetNext("offset", 0)}
+
+	t.Run("all", func(t *testing.T) {
+		atomic.StoreInt32(&requests, 0)
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+
+		var ids []int
+		for u, err := range Paginate(context.Background(), NewClient(), req, cfg) {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			ids = append(ids, u.ID)
+		}
+
+		if len(ids) != 6 || ids[5] != 6 {
+			t.Errorf("expected ids 1 to 6, got %v", ids)
+		}
+		if n := atomic.LoadInt32(&requests); n != 4 {
+			t.Errorf("expected 4 requests, got %d", n)
+		}
+	})
+
+	t.Run("break", func(t *testing.T) {
+		atomic.StoreInt32(&requests, 0)
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+
+		for u, err := range Paginate(context.Background(), NewClient(), req, cfg) {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if u.ID == 3 {
+				break
+			}
+		}
+
+		if n := atomic.LoadInt32(&requests); n != 2 {
+			t.Errorf("expected 2 requests, got %d", n)
+		}
+	})
+
+	t.Run("max pages", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		cfg := cfg
+		cfg.MaxPages = 2
+
+		var n int
+		var lastErr error
+		for _, err := range Paginate(context.Background(), NewClient(), req, cfg) {
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			n++
+		}
+
+		if n != 4 {
+			t.Errorf("expected 4 items, got %d", n)
+		}
+		if !errors.Is(lastErr, ErrMaxPages) {
+			t.Errorf("expected ErrMaxPages, got %v", lastErr)
+		}
+	})
+}
@@ -0,0 +1,224 @@
+package httpz
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestLinkNext(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   string
+	}{
+		{"none", nil, ""},
+		{"next", []string{`<https://a.com/items?page=2>; rel="next"`}, "https://a.com/items?page=2"},
+		{"multiple links", []string{`<https://a.com/?page=1>; rel="prev", <https://a.com/?page=3>; rel="next"`}, "https://a.com/?page=3"},
+		{"multiple values", []string{`</first>; rel=first`, `</next>; title="n"; rel="last next"`}, "/next"},
+		{"no next", []string{`</last>; rel="last"`}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := linkNext(tt.values); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLinkNext_CrossHost(t *testing.T) {
+	var got []string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("Authorization"), r.Header.Get("Cookie"), r.Header.Get("X-Trace"))
+		_, _ = w.Write([]byte(`[{"id":3}]`))
+	}))
+	defer other.Close()
+
+	var first []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		first = append(first, r.Header.Get("Authorization"))
+		w.Header().Set("Link", `<`+other.URL+`/items?page=2>; rel="next"`)
+		_, _ = w.Write([]byte(`[{"id":1},{"id":2}]`))
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/items", nil)
+	req.Header.Set("Authorization", "Bearer abc")
+	req.Header.Set("Cookie", "session=secret")
+	req.Header.Set("X-Trace", "t1")
+
+	var ids []int
+	pager := NewPager[typedUser](NewClient(), req, PageConfig[typedUser]{Codec: JSONCodec{}})
+	for {
+		items, err := pager.NextPage()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+	}
+
+	if len(ids) != 3 || len(first) != 1 || first[0] != "Bearer abc" {
+		t.Fatalf("unexpected pages %v, first request %v", ids, first)
+	}
+	if len(got) != 3 || got[0] != "" || got[1] != "" || got[2] != "t1" {
+		t.Errorf("expected the credentials not to be sent to another host, got %q", got)
+	}
+	if req.Header.Get("Authorization") != "Bearer abc" {
+		t.Error("expected the original request not to be changed")
+	}
+}
+
+func TestPager_NextPage(t *testing.T) {
+	const total = 5
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		start := 0
+		switch r.URL.Path {
+		case "/link":
+			start, _ = strconv.Atoi(query.Get("from"))
+			if start+2 < total {
+				w.Header().Set("Link", `</link?from=`+strconv.Itoa(start+2)+`>; rel="next"`)
+			}
+		case "/cursor":
+			start, _ = strconv.Atoi(query.Get("cursor"))
+		case "/page":
+			if p := query.Get("page"); p != "" {
+				n, _ := strconv.Atoi(p)
+				start = (n - 1) * 2
+			}
+		case "/offset":
+			start, _ = strconv.Atoi(query.Get("offset"))
+		}
+
+		var items []typedUser
+		for i := start; i < start+2 && i < total; i++ {
+			items = append(items, typedUser{ID: i + 1})
+		}
+
+		w.Header().Set("Content-Type", MIMEJSON)
+		if r.URL.Path == "/cursor" {
+			next := ""
+			if start+2 < total {
+				next = strconv.Itoa(start + 2)
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"items": items, "next": next})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(items)
+	}))
+	defer server.Close()
+
+	type cursorPage struct {
+		Items []typedUser `json:"items"`
+		Next  string      `json:"next"`
+	}
+
+	tests := []struct {
+		name  string
+		path  string
+		cfg   PageConfig[typedUser]
+		pages int
+	}{
+		{"link", "/link", PageConfig[typedUser]{}, 3},
+		{"cursor", "/cursor", PageConfig[typedUser]{
+			Items: func(resp *http.Response, body []byte) ([]typedUser, error) {
+				var page cursorPage
+				err := json.Unmarshal(body, &page)
+				return page.Items, err
+			},
+			Next: CursorNext("cursor", func(body []byte) (string, error) {
+				var page cursorPage
+				err := json.Unmarshal(body, &page)
+				return page.Next, err
+			}),
+		}, 3},
+		{"page number", "/page", PageConfig[typedUser]{Next: PageNumberNext("page", 0)}, 4},
+		{"page number with page size", "/page", PageConfig[typedUser]{Next: PageNumberNext("page", 2)}, 3},
+		{"offset", "/offset?limit=2", PageConfig[typedUser]{Next: OffsetNext("offset", 2)}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, server.URL+tt.path, nil)
+			p := NewPager(NewClient(), req, tt.cfg)
+
+			var ids []int
+			for {
+				items, err := p.NextPage()
+				if err != nil {
+					if !errors.Is(err, io.EOF) {
+						t.Fatalf("unexpected error: %v", err)
+					}
+					break
+				}
+				for _, item := range items {
+					ids = append(ids, item.ID)
+				}
+			}
+
+			if len(ids) != total {
+				t.Fatalf("expected %d items, got %v", total, ids)
+			}
+			for i, id := range ids {
+				if id != i+1 {
+					t.Errorf("expected id %d, got %d", i+1, id)
+				}
+			}
+			if p.Pages() != tt.pages {
+				t.Errorf("expected %d pages, got %d", tt.pages, p.Pages())
+			}
+		})
+	}
+}
+
+func TestPager_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "3" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", MIMEJSON)
+		_, _ = w.Write([]byte(`[{"id":1}]`))
+	}))
+	defer server.Close()
+
+	t.Run("max pages", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		p := NewPager(NewClient(), req, PageConfig[typedUser]{Next: PageNumberNext("page", 0), MaxPages: 1})
+		if _, err := p.NextPage(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := p.NextPage(); !errors.Is(err, ErrMaxPages) {
+			t.Errorf("expected ErrMaxPages, got %v", err)
+		}
+	})
+
+	t.Run("http error", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		p := NewPager(NewClient(), req, PageConfig[typedUser]{Next: PageNumberNext("page", 0)})
+
+		var err error
+		for err == nil {
+			_, err = p.NextPage()
+		}
+
+		var httpErr *HTTPError
+		if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected 404 HTTPError, got %v", err)
+		}
+		if _, err = p.NextPage(); !errors.Is(err, io.EOF) {
+			t.Errorf("expected io.EOF after an error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package httpz
+
+import (
+	"io"
+	"sync"
+)
+
+// lazyPipe is a reader of the data written by produce through an io.Pipe.
+// The producing goroutine is started on the first Read, so a body which is never sent does not leak it.
+type lazyPipe struct {
+	// produce writes the data, its error is returned to the reader
+	produce func(w io.Writer) error
+	// src is closed once produce returns, or by Close if produce is never started
+	src io.Closer
+
+	mu     sync.Mutex
+	pr     *io.PipeReader
+	closed bool
+}
+
+func (p *lazyPipe) Read(b []byte) (int, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return 0, io.ErrClosedPipe
+	}
+	if p.pr == nil {
+		var pw *io.PipeWriter
+		p.pr, pw = io.Pipe()
+		go func() {
+			err := p.produce(pw)
+			if p.src != nil {
+				_ = p.src.Close()
+			}
+			_ = pw.CloseWithError(err)
+		}()
+	}
+	pr := p.pr
+	p.mu.Unlock()
+
+	return pr.Read(b)
+}
+
+// Close stops the producing goroutine, the writes of produce fail once the reader is closed.
+func (p *lazyPipe) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	pr := p.pr
+	p.mu.Unlock()
+
+	if pr != nil {
+		return pr.Close()
+	}
+	if p.src != nil {
+		return p.src.Close()
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package httpz
+
+import (
+	"errors"
+	"io"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLazyPipe(t *testing.T) {
+	var produced, closed int32
+	newPipe := func(err error) *lazyPipe {
+		return &lazyPipe{
+			produce: func(w io.Writer) error {
+				atomic.AddInt32(&produced, 1)
+				_, _ = io.WriteString(w, "data")
+				return err
+			},
+			src: &closeFunc{Reader: strings.NewReader(""), fn: func() { atomic.AddInt32(&closed, 1) }},
+		}
+	}
+
+	before := runtime.NumGoroutine()
+	p := newPipe(nil)
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("expected no goroutine before the first read, got %d before and %d after", before, after)
+	}
+	if err := p.Close(); err != nil || atomic.LoadInt32(&produced) != 0 || atomic.LoadInt32(&closed) != 1 {
+		t.Fatalf("expected the unread pipe to close src only, got %d produced %d closed %v", produced, closed, err)
+	}
+	if _, err := p.Read(make([]byte, 1)); err != io.ErrClosedPipe {
+		t.Errorf("expected io.ErrClosedPipe, got %v", err)
+	}
+
+	b, err := io.ReadAll(newPipe(nil))
+	if string(b) != "data" || err != nil || atomic.LoadInt32(&closed) != 2 {
+		t.Errorf("unexpected read %q %v, closed %d", b, err, closed)
+	}
+
+	errProduce := errors.New("produce")
+	if _, err = io.ReadAll(newPipe(errProduce)); err != errProduce {
+		t.Errorf("expected the produce error, got %v", err)
+	}
+}
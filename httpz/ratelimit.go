@@ -0,0 +1,264 @@
+package httpz
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited reports that the request was rejected by the rate limiter.
+var ErrRateLimited = errors.New("httpz: rate limited")
+
+// RateLimitError is returned when the rate limiter rejects the request.
+type RateLimitError struct {
+	// Key the rate limit key of the request
+	Key string
+	// RetryAfter the duration until a token is available
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return ErrRateLimited.Error() + ": key " + e.Key + ", retry after " + e.RetryAfter.String()
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// RateLimitConfig configures a RateLimiter.
+type RateLimitConfig struct {
+	// Rate tokens added per second. Less than or equal to 0 means no limit
+	Rate float64
+	// Burst capacity of the bucket. Default is the ceil of Rate, at least 1
+	Burst int
+	// KeyFunc returns the rate limit key of the request. Default is the request host
+	KeyFunc func(req *http.Request) string
+	// Wait waits for a token while respecting the request context instead of rejecting. Default is false
+	Wait bool
+	// MaxWait maximum wait duration in Wait mode, requests needing longer waits are rejected.
+	// Default is 0, means only limited by the request context deadline
+	MaxWait time.Duration
+}
+
+// RateLimiter applies token bucket limits per key. The limits can be adjusted at runtime.
+type RateLimiter struct {
+	mu        sync.Mutex
+	cfg       RateLimitConfig
+	keyLimits map[string]bucketLimit
+	buckets   map[string]*tokenBucket
+}
+
+type bucketLimit struct {
+	rate  float64
+	burst float64
+}
+
+type tokenBucket struct {
+	bucketLimit
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a new RateLimiter.
+func NewRateLimiter(cfg RateLimitConfig) *RateLimiter {
+	if cfg.KeyFunc == nil {
+		cfg.KeyFunc = hostKey
+	}
+
+	return &RateLimiter{
+		cfg:       cfg,
+		keyLimits: make(map[string]bucketLimit),
+		buckets:   make(map[string]*tokenBucket),
+	}
+}
+
+// Middleware returns the middleware which waits for a token or fails with *RateLimitError.
+func (l *RateLimiter) Middleware() Middleware {
+	return func(next DoFunc) DoFunc {
+		return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			if err := l.take(ctx, l.cfg.KeyFunc(req)); err != nil {
+				return nil, err
+			}
+
+			return next(ctx, req)
+		}
+	}
+}
+
+// Allow reports whether a token of the key is available now, and takes it if so.
+func (l *RateLimiter) Allow(key string) bool {
+	_, ok := l.reserve(key, 0)
+	return ok
+}
+
+// Wait waits for a token of the key until the ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context, key string) error {
+	wait, ok := l.reserve(key, l.maxWait(ctx))
+	if !ok {
+		return &RateLimitError{Key: key, RetryAfter: wait}
+	}
+
+	if err := sleepContext(ctx, wait); err != nil {
+		l.refund(key)
+		return err
+	}
+	return nil
+}
+
+// SetLimit changes the default limit of all keys without a key-specific limit.
+func (l *RateLimiter) SetLimit(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.cfg.Rate = rate
+	l.cfg.Burst = burst
+
+	now := time.Now()
+	limit := newBucketLimit(rate, burst)
+	for key, b := range l.buckets {
+		if _, ok := l.keyLimits[key]; !ok {
+			b.setLimit(limit, now)
+		}
+	}
+}
+
+// SetKeyLimit changes the limit of the key.
+func (l *RateLimiter) SetKeyLimit(key string, rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limit := newBucketLimit(rate, burst)
+	l.keyLimits[key] = limit
+	if b, ok := l.buckets[key]; ok {
+		b.setLimit(limit, time.Now())
+	}
+}
+
+// Tokens returns the current tokens of the key.
+// The result may be negative when waiting requests have reserved future tokens.
+func (l *RateLimiter) Tokens(key string) float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.bucket(key, time.Now())
+	return b.tokens
+}
+
+func (l *RateLimiter) take(ctx context.Context, key string) error {
+	if !l.cfg.Wait {
+		if wait, ok := l.reserve(key, 0); !ok {
+			return &RateLimitError{Key: key, RetryAfter: wait}
+		}
+		return nil
+	}
+
+	return l.Wait(ctx, key)
+}
+
+// maxWait returns the maximum wait duration for the ctx, -1 means no limit.
+func (l *RateLimiter) maxWait(ctx context.Context) time.Duration {
+	maxWait := time.Duration(-1)
+	if l.cfg.MaxWait > 0 {
+		maxWait = l.cfg.MaxWait
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		remain := time.Until(deadline)
+		if remain < 0 {
+			remain = 0
+		}
+		if maxWait < 0 || remain < maxWait {
+			maxWait = remain
+		}
+	}
+
+	return maxWait
+}
+
+// reserve takes a token of the key and returns the duration until the token is available.
+// The token is not taken if the duration exceeds maxWait, unless maxWait is negative.
+func (l *RateLimiter) reserve(key string, maxWait time.Duration) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.bucket(key, time.Now())
+	if b.rate <= 0 {
+		return 0, true
+	}
+
+	var wait time.Duration
+	if b.tokens < 1 {
+		wait = time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	}
+
+	if maxWait >= 0 && wait > maxWait {
+		return wait, false
+	}
+
+	b.tokens--
+	return wait, true
+}
+
+func (l *RateLimiter) refund(key string) {
+	l.mu.Lock()
+	b := l.bucket(key, time.Now())
+	if b.rate > 0 {
+		b.tokens = math.Min(b.tokens+1, b.burst)
+	}
+	l.mu.Unlock()
+}
+
+// bucket returns the bucket of the key refilled to now, the caller must hold the lock.
+func (l *RateLimiter) bucket(key string, now time.Time) *tokenBucket {
+	b, ok := l.buckets[key]
+	if !ok {
+		limit, ok := l.keyLimits[key]
+		if !ok {
+			limit = newBucketLimit(l.cfg.Rate, l.cfg.Burst)
+		}
+
+		b = &tokenBucket{bucketLimit: limit, tokens: limit.burst, last: now}
+		l.buckets[key] = b
+		return b
+	}
+
+	b.refill(now)
+	return b
+}
+
+func newBucketLimit(rate float64, burst int) bucketLimit {
+	if burst <= 0 {
+		burst = int(math.Ceil(rate))
+		if burst < 1 {
+			burst = 1
+		}
+	}
+
+	return bucketLimit{rate: rate, burst: float64(burst)}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last)
+	if elapsed <= 0 {
+		return
+	}
+
+	b.last = now
+	if b.rate <= 0 {
+		b.tokens = b.burst
+		return
+	}
+
+	b.tokens = math.Min(b.tokens+elapsed.Seconds()*b.rate, b.burst)
+}
+
+func (b *tokenBucket) setLimit(limit bucketLimit, now time.Time) {
+	b.refill(now)
+	b.bucketLimit = limit
+	if b.tokens > limit.burst {
+		b.tokens = limit.burst
+	}
+}
@@ -0,0 +1,106 @@
+package httpz
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Reject(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	limiter := NewRateLimiter(RateLimitConfig{Rate: 10, Burst: 2})
+	client := NewClient(WithMiddleware(limiter.Middleware()))
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	_, err := client.Do(req)
+	var rlErr *RateLimitError
+	if !errors.As(err, &rlErr) || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected RateLimitError, got %v", err)
+	}
+	if rlErr.Key != req.URL.Host || rlErr.RetryAfter <= 0 || rlErr.RetryAfter > 100*time.Millisecond {
+		t.Errorf("unexpected error fields: %+v", rlErr)
+	}
+}
+
+func TestRateLimiter_Wait(t *testing.T) {
+	limiter := NewRateLimiter(RateLimitConfig{Rate: 20, Burst: 1, Wait: true})
+	do := limiter.Middleware()(func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	begin := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := do(req.Context(), req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if cost := time.Since(begin); cost < 90*time.Millisecond {
+		t.Errorf("expected to wait about 100ms, cost %v", cost)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := do(ctx, req); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected rejection when the deadline is shorter than the wait, got %v", err)
+	}
+
+	slow := NewRateLimiter(RateLimitConfig{Rate: 0.1, Burst: 1})
+	if !slow.Allow("k") {
+		t.Fatal("expected the first token to be available")
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if err := slow.Wait(ctx, "k"); err != context.Canceled {
+		t.Fatalf("expected context canceled, got %v", err)
+	}
+	if tokens := slow.Tokens("k"); tokens < 0 {
+		t.Errorf("expected the reserved token to be refunded, got %v", tokens)
+	}
+}
+
+func TestRateLimiter_SetLimit(t *testing.T) {
+	limiter := NewRateLimiter(RateLimitConfig{Rate: 1, Burst: 5})
+	if tokens := limiter.Tokens("a"); tokens != 5 {
+		t.Errorf("expected 5 tokens, got %v", tokens)
+	}
+
+	limiter.SetLimit(1, 2)
+	if tokens := limiter.Tokens("a"); tokens != 2 {
+		t.Errorf("expected tokens to be capped to 2, got %v", tokens)
+	}
+	if !limiter.Allow("a") || !limiter.Allow("a") || limiter.Allow("a") {
+		t.Error("expected 2 tokens to be allowed")
+	}
+
+	limiter.SetKeyLimit("b", 1, 1)
+	if !limiter.Allow("b") || limiter.Allow("b") {
+		t.Error("expected key limit of 1 token")
+	}
+
+	limiter.SetLimit(0, 0)
+	for i := 0; i < 10; i++ {
+		if !limiter.Allow("a") {
+			t.Fatal("expected no limit when rate is 0")
+		}
+	}
+	if limiter.Allow("b") {
+		t.Error("expected key limit to be kept")
+	}
+}
@@ -0,0 +1,384 @@
+package httpz
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/welllog/golib/hashz"
+)
+
+// ErrInteractionNotFound reports that no recorded interaction matches the request in replay mode.
+var ErrInteractionNotFound = errors.New("httpz: no recorded interaction matches the request")
+
+// RecordMode is the mode of a Recorder.
+type RecordMode int
+
+const (
+	// ModeReplay replays the recorded interactions, unmatched requests fail with ErrInteractionNotFound.
+	ModeReplay RecordMode = iota
+	// ModeRecord sends all requests to the upstream and records them, the existing cassette is replaced.
+	ModeRecord
+	// ModeReplayOrRecord replays the matched interactions, and records the unmatched requests.
+	ModeReplayOrRecord
+	// ModePassthrough sends all requests to the upstream without recording.
+	ModePassthrough
+)
+
+// Cassette is the recorded interactions.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions" yaml:"interactions"`
+}
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request" yaml:"request"`
+	Response RecordedResponse `json:"response" yaml:"response"`
+}
+
+// RecordedRequest is a recorded request with the sensitive values redacted.
+type RecordedRequest struct {
+	Method   string      `json:"method" yaml:"method"`
+	URL      string      `json:"url" yaml:"url"`
+	Header   http.Header `json:"header,omitempty" yaml:"header,omitempty"`
+	Body     string      `json:"body,omitempty" yaml:"body,omitempty"`
+	BodyHash string      `json:"body_hash,omitempty" yaml:"body_hash,omitempty"`
+	// Base64 reports whether Body is base64 encoded, binary bodies are base64 encoded
+	Base64 bool `json:"base64,omitempty" yaml:"base64,omitempty"`
+}
+
+// RecordedResponse is a recorded response with the sensitive values redacted.
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code" yaml:"status_code"`
+	Header     http.Header `json:"header,omitempty" yaml:"header,omitempty"`
+	Body       string      `json:"body,omitempty" yaml:"body,omitempty"`
+	// Base64 reports whether Body is base64 encoded, binary bodies are base64 encoded
+	Base64 bool `json:"base64,omitempty" yaml:"base64,omitempty"`
+}
+
+// RecordConfig configures a Recorder.
+type RecordConfig struct {
+	// Path path of the cassette file
+	Path string
+	// Mode mode of the Recorder. Default is ModeReplay
+	Mode RecordMode
+	// Codec encodes the cassette file. Default is indented JSON.
+	// No YAML codec is shipped to keep the module free of dependencies, YAML cassettes are written by
+	// a YAML codec, e.g. an adapter of gopkg.in/yaml.v3, which follows the yaml tags of the cassette types
+	Codec Codec
+	// Transport sends the requests to the upstream. Default is http.DefaultTransport
+	Transport http.RoundTripper
+	// MatchHeaders header names which must be equal to match an interaction, besides the method and url
+	MatchHeaders []string
+	// MatchBody requires the body hash to be equal to match an interaction
+	MatchBody bool
+	// Matcher custom matcher, it replaces the default matching if set. body is the redacted request body
+	Matcher func(req *http.Request, body []byte, recorded *RecordedRequest) bool
+	// RedactHeaders header names whose values are redacted. Default is the default of LogConfig.RedactHeaders
+	RedactHeaders []string
+	// RedactQuery query parameter and form field names whose values are redacted.
+	// Default is the default of LogConfig.RedactQuery
+	RedactQuery []string
+	// RedactFields JSON body field names whose values are redacted. Default is the default of LogConfig.RedactFields
+	RedactFields []string
+}
+
+// Recorder is an http.RoundTripper which records the interactions to a cassette file and replays them offline.
+// It can be used by a Client through WithHttpClient(&http.Client{Transport: recorder}).
+// Sensitive values are redacted before being recorded, and the requests are redacted the same way before
+// matching, so the redacted values do not affect matching.
+type Recorder struct {
+	cfg      RecordConfig
+	redactor *RequestLogger
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+	changed  bool
+}
+
+// NewRecorder creates a new Recorder, the cassette file is loaded unless the mode is ModeRecord or ModePassthrough.
+// A missing cassette file is an error in ModeReplay.
+func NewRecorder(cfg RecordConfig) (*Recorder, error) {
+	if cfg.Transport == nil {
+		cfg.Transport = http.DefaultTransport
+	}
+
+	r := &Recorder{
+		cfg: cfg,
+		redactor: NewRequestLogger(LogConfig{
+			RedactHeaders: cfg.RedactHeaders,
+			RedactQuery:   cfg.RedactQuery,
+			RedactFields:  cfg.RedactFields,
+		}),
+	}
+
+	if cfg.Mode == ModeRecord || cfg.Mode == ModePassthrough {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(cfg.Path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && cfg.Mode == ModeReplayOrRecord {
+			return r, nil
+		}
+		return nil, fmt.Errorf("httpz: read cassette failed: %w", err)
+	}
+
+	if err = r.unmarshal(data, &r.cassette); err != nil {
+		return nil, fmt.Errorf("httpz: decode cassette failed: %w", err)
+	}
+	r.used = make([]bool, len(r.cassette.Interactions))
+
+	return r, nil
+}
+
+// Cassette returns a copy of the interactions of the Recorder.
+func (r *Recorder) Cassette() Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return Cassette{Interactions: append([]Interaction(nil), r.cassette.Interactions...)}
+}
+
+// Save writes the cassette file if new interactions were recorded.
+func (r *Recorder) Save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.changed {
+		return nil
+	}
+
+	data, err := r.marshal(r.cassette)
+	if err != nil {
+		return fmt.Errorf("httpz: encode cassette failed: %w", err)
+	}
+
+	if err = os.WriteFile(r.cfg.Path, data, 0o644); err != nil {
+		return fmt.Errorf("httpz: write cassette failed: %w", err)
+	}
+
+	r.changed = false
+	return nil
+}
+
+// RoundTrip replays or records the request by the mode.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if r.cfg.Mode == ModePassthrough {
+		return r.cfg.Transport.RoundTrip(req)
+	}
+
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	recorded := r.recordRequest(req, body)
+
+	if r.cfg.Mode != ModeRecord {
+		if resp, ok := r.replay(req, recorded); ok {
+			return resp, nil
+		}
+		if r.cfg.Mode == ModeReplay {
+			return nil, fmt.Errorf("%w: %s %s", ErrInteractionNotFound, recorded.Method, recorded.URL)
+		}
+	}
+
+	out := req.Clone(req.Context())
+	if body != nil {
+		out.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	resp, err := r.cfg.Transport.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	r.record(Interaction{Request: recorded, Response: r.recordResponse(resp, respBody)})
+	return resp, nil
+}
+
+// replay returns the response of the first unused matched interaction,
+// or the last matched one if all matched interactions were used.
+func (r *Recorder) replay(req *http.Request, recorded RecordedRequest) (*http.Response, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	matched := -1
+	for i := range r.cassette.Interactions {
+		if !r.match(req, recorded, &r.cassette.Interactions[i].Request) {
+			continue
+		}
+
+		matched = i
+		if !r.used[i] {
+			break
+		}
+	}
+
+	if matched < 0 {
+		return nil, false
+	}
+
+	r.used[matched] = true
+	return r.cassette.Interactions[matched].Response.response(req), true
+}
+
+func (r *Recorder) match(req *http.Request, recorded RecordedRequest, candidate *RecordedRequest) bool {
+	if r.cfg.Matcher != nil {
+		body, _ := decodeRecordedBody(recorded.Body, recorded.Base64)
+		return r.cfg.Matcher(req, body, candidate)
+	}
+
+	if recorded.Method != candidate.Method || recorded.URL != candidate.URL {
+		return false
+	}
+
+	if r.cfg.MatchBody && recorded.BodyHash != candidate.BodyHash {
+		return false
+	}
+
+	for _, h := range r.cfg.MatchHeaders {
+		if recorded.Header.Get(h) != candidate.Header.Get(h) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *Recorder) record(interaction Interaction) {
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	r.used = append(r.used, true)
+	r.changed = true
+	r.mu.Unlock()
+}
+
+func (r *Recorder) recordRequest(req *http.Request, body []byte) RecordedRequest {
+	recorded := RecordedRequest{
+		Method: req.Method,
+		URL:    r.redactor.redactURL(req.URL),
+		Header: r.redactHeader(req.Header),
+	}
+
+	if len(body) > 0 {
+		redactedBody := r.redactor.redactBody(req.Header, body)
+		recorded.Body, recorded.Base64 = encodeRecordedBody(redactedBody)
+		recorded.BodyHash = hashz.Sha256ToString(redactedBody)
+	}
+
+	return recorded
+}
+
+func (r *Recorder) recordResponse(resp *http.Response, body []byte) RecordedResponse {
+	recorded := RecordedResponse{
+		StatusCode: resp.StatusCode,
+		Header:     r.redactHeader(resp.Header),
+	}
+
+	if len(body) > 0 {
+		recorded.Body, recorded.Base64 = encodeRecordedBody(r.redactor.redactBody(resp.Header, body))
+	}
+
+	return recorded
+}
+
+func (r *Recorder) redactHeader(header http.Header) http.Header {
+	if len(header) == 0 {
+		return nil
+	}
+
+	redactedHeader := make(http.Header, len(header))
+	for k, vs := range header {
+		if _, ok := r.redactor.headers[http.CanonicalHeaderKey(k)]; ok {
+			redactedHeader[k] = []string{redacted}
+			continue
+		}
+		redactedHeader[k] = append([]string(nil), vs...)
+	}
+	return redactedHeader
+}
+
+func (r *Recorder) marshal(v any) ([]byte, error) {
+	if r.cfg.Codec != nil {
+		return r.cfg.Codec.Marshal(v)
+	}
+	return json.MarshalIndent(v, "", "  ")
+}
+
+func (r *Recorder) unmarshal(data []byte, v any) error {
+	if r.cfg.Codec != nil {
+		return r.cfg.Codec.Unmarshal(data, v)
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (rr *RecordedResponse) response(req *http.Request) *http.Response {
+	body, _ := decodeRecordedBody(rr.Body, rr.Base64)
+	header := make(http.Header, len(rr.Header))
+	for k, vs := range rr.Header {
+		header[k] = append([]string(nil), vs...)
+	}
+
+	return &http.Response{
+		Status:        strconv.Itoa(rr.StatusCode) + " " + http.StatusText(rr.StatusCode),
+		StatusCode:    rr.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// readRequestBody reads the request body through req.GetBody if possible, otherwise the body is consumed.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body := req.Body
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		_ = req.Body.Close()
+		body = rc
+	}
+
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+func encodeRecordedBody(body string) (string, bool) {
+	if utf8.ValidString(body) {
+		return body, false
+	}
+	return base64.StdEncoding.EncodeToString([]byte(body)), true
+}
+
+func decodeRecordedBody(body string, isBase64 bool) ([]byte, error) {
+	if !isBase64 {
+		return []byte(body), nil
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(body))
+}
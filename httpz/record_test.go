@@ -0,0 +1,201 @@
+package httpz
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret")
+		switch r.URL.Path {
+		case "/bin":
+			_, _ = w.Write([]byte{0xff, 0x00, 0xfe})
+		default:
+			_, _ = w.Write([]byte(`{"name":"` + r.URL.Query().Get("name") + `","token":"t1"}`))
+		}
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	rec, err := NewRecorder(RecordConfig{Path: path, Mode: ModeRecord, MatchBody: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	client := NewClient(WithHttpClient(&http.Client{Transport: rec}))
+	ctx := context.Background()
+	headers := map[string]string{"Authorization": "Bearer abc"}
+
+	for _, name := range []string{"a", "b"} {
+		user, err := Post[map[string]string](ctx, client, server.URL+"/user?name="+name+"&token=abc", headers,
+			map[string]string{"password": "pw"}, JSONCodec{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if user["name"] != name || user["token"] != "t1" {
+			t.Errorf("unexpected live response: %v", user)
+		}
+	}
+
+	bin, err := Get[[]byte](ctx, client, server.URL+"/bin", nil, nil)
+	if err != nil || len(bin) != 3 {
+		t.Fatalf("unexpected bin response: %v %v", bin, err)
+	}
+
+	if err = rec.Save(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	for _, leak := range []string{"Bearer abc", "session=secret", `"pw"`, `t1`, "token=abc"} {
+		if strings.Contains(string(data), leak) {
+			t.Errorf("unexpected %q in cassette: %s", leak, data)
+		}
+	}
+	if len(rec.Cassette().Interactions) != 3 {
+		t.Errorf("expected 3 interactions, got %d", len(rec.Cassette().Interactions))
+	}
+
+	server.Close()
+
+	rec, err = NewRecorder(RecordConfig{Path: path, MatchBody: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client = NewClient(WithHttpClient(&http.Client{Transport: rec}))
+
+	for _, name := range []string{"b", "a"} {
+		user, err := Post[map[string]string](ctx, client, server.URL+"/user?name="+name+"&token=other", headers,
+			map[string]string{"password": "other"}, JSONCodec{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if user["name"] != name || user["token"] != redacted {
+			t.Errorf("unexpected replayed response: %v", user)
+		}
+	}
+
+	bin, err = Get[[]byte](ctx, client, server.URL+"/bin", nil, nil)
+	if err != nil || string(bin) != string([]byte{0xff, 0x00, 0xfe}) {
+		t.Errorf("unexpected replayed bin response: %v %v", bin, err)
+	}
+
+	_, err = Post[map[string]string](ctx, client, server.URL+"/user?name=a&token=abc", headers,
+		map[string]string{"password": "pw", "extra": "1"}, JSONCodec{})
+	if !errors.Is(err, ErrInteractionNotFound) {
+		t.Errorf("expected ErrInteractionNotFound, got %v", err)
+	}
+
+	if _, err = NewRecorder(RecordConfig{Path: filepath.Join(t.TempDir(), "missing.json")}); err == nil {
+		t.Error("expected error on missing cassette in replay mode")
+	}
+}
+
+func TestRecorder_ReplayOrRecord(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = w.Write([]byte(r.Header.Get("X-Tenant")))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	rec, err := NewRecorder(RecordConfig{Path: path, Mode: ModeReplayOrRecord, MatchHeaders: []string{"X-Tenant"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client := NewClient(WithHttpClient(&http.Client{Transport: rec}))
+
+	for _, tenant := range []string{"a", "b", "a", "b"} {
+		out, err := Get[string](context.Background(), client, server.URL, map[string]string{"X-Tenant": tenant}, nil)
+		if err != nil || out != tenant {
+			t.Errorf("unexpected response: %s %v", out, err)
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("expected 2 upstream calls, got %d", calls)
+	}
+}
+
+func TestRecorder_Codec(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("X-Multi", "1")
+		w.Header().Add("X-Multi", "2")
+		_, _ = w.Write([]byte{0xff, 0x00, 0xfe})
+	}))
+	defer server.Close()
+
+	codec := &markerCodec{}
+	path := filepath.Join(t.TempDir(), "cassette.txt")
+	rec, err := NewRecorder(RecordConfig{Path: path, Mode: ModeRecord, Codec: codec})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client := NewClient(WithHttpClient(&http.Client{Transport: rec}))
+	if _, err = Get[[]byte](context.Background(), client, server.URL+"/bin", nil, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = rec.Save(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if codec.marshaled != 1 || !bytes.HasPrefix(data, []byte(cassetteMarker)) {
+		t.Fatalf("expected the cassette to be saved by the codec, got %d calls: %s", codec.marshaled, data)
+	}
+
+	server.Close()
+	rec, err = NewRecorder(RecordConfig{Path: path, Codec: codec})
+	if err != nil || codec.unmarshaled != 1 {
+		t.Fatalf("expected the cassette to be loaded by the codec, got %d calls: %v", codec.unmarshaled, err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/bin", nil)
+	resp, err := NewClient(WithHttpClient(&http.Client{Transport: rec})).Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != string([]byte{0xff, 0x00, 0xfe}) || strings.Join(resp.Header.Values("X-Multi"), ",") != "1,2" {
+		t.Errorf("unexpected replayed response: %v %v", body, resp.Header)
+	}
+
+	if _, err = NewRecorder(RecordConfig{Path: path}); err == nil {
+		t.Error("expected error of decoding the cassette without the codec")
+	}
+}
+
+const cassetteMarker = "cassette:"
+
+// markerCodec is JSON with a marker prefix, it checks that the cassette goes through the configured codec.
+type markerCodec struct {
+	marshaled   int
+	unmarshaled int
+}
+
+func (c *markerCodec) Marshal(v any) ([]byte, error) {
+	c.marshaled++
+	b, err := json.Marshal(v)
+	return append([]byte(cassetteMarker), b...), err
+}
+
+func (c *markerCodec) Unmarshal(data []byte, v any) error {
+	c.unmarshaled++
+	if !bytes.HasPrefix(data, []byte(cassetteMarker)) {
+		return errors.New("missing cassette marker")
+	}
+	return json.Unmarshal(data[len(cassetteMarker):], v)
+}
@@ -0,0 +1,130 @@
+package httpz
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultBudgetWindow  = 10 * time.Second
+	defaultBudgetBuckets = 10
+)
+
+// RetryBudget limits retries of all requests sharing it to a ratio of requests over a sliding window,
+// which prevents retry storms during a partial outage. It is safe for concurrent use.
+type RetryBudget struct {
+	mu         sync.Mutex
+	ratio      float64
+	minRetries float64
+	slot       int64
+	buckets    []budgetBucket
+	head       int
+	headStart  int64
+	requests   int64
+	retries    int64
+	stats      RetryBudgetStats
+}
+
+type budgetBucket struct {
+	requests int64
+	retries  int64
+}
+
+// RetryBudgetStats counters of a RetryBudget since it was created.
+type RetryBudgetStats struct {
+	// Requests number of requests deposited
+	Requests uint64
+	// Retries number of retries allowed
+	Retries uint64
+	// Denied number of retries denied
+	Denied uint64
+}
+
+// NewRetryBudget creates a new RetryBudget.
+// ratio is the maximum ratio of retries to requests in the window, e.g. 0.2 means retries <= 20% of requests.
+// minPerSecond is the minimum retries per second always allowed, so that retries are possible under low traffic.
+// window is the length of the sliding window, if window is less than or equal to 0, it defaults to 10s.
+func NewRetryBudget(ratio float64, minPerSecond int, window time.Duration) *RetryBudget {
+	if ratio < 0 {
+		ratio = 0
+	}
+	if minPerSecond < 0 {
+		minPerSecond = 0
+	}
+	if window <= 0 {
+		window = defaultBudgetWindow
+	}
+
+	slot := int64(window) / defaultBudgetBuckets
+	if slot <= 0 {
+		slot = 1
+	}
+
+	return &RetryBudget{
+		ratio:      ratio,
+		minRetries: float64(minPerSecond) * window.Seconds(),
+		slot:       slot,
+		buckets:    make([]budgetBucket, defaultBudgetBuckets),
+		headStart:  time.Now().UnixNano(),
+	}
+}
+
+// Deposit records a request.
+func (b *RetryBudget) Deposit() {
+	b.mu.Lock()
+	b.advance(time.Now().UnixNano())
+	b.buckets[b.head].requests++
+	b.requests++
+	b.stats.Requests++
+	b.mu.Unlock()
+}
+
+// Withdraw reports whether a retry is allowed, and records it if so.
+func (b *RetryBudget) Withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance(time.Now().UnixNano())
+	if float64(b.retries+1) > b.ratio*float64(b.requests)+b.minRetries {
+		b.stats.Denied++
+		return false
+	}
+
+	b.buckets[b.head].retries++
+	b.retries++
+	b.stats.Retries++
+	return true
+}
+
+// Stats returns the counters of the RetryBudget.
+func (b *RetryBudget) Stats() RetryBudgetStats {
+	b.mu.Lock()
+	stats := b.stats
+	b.mu.Unlock()
+	return stats
+}
+
+// advance expires the buckets out of the sliding window.
+func (b *RetryBudget) advance(now int64) {
+	n := (now - b.headStart) / b.slot
+	if n <= 0 {
+		return
+	}
+
+	if n >= int64(len(b.buckets)) {
+		for i := range b.buckets {
+			b.buckets[i] = budgetBucket{}
+		}
+		b.requests = 0
+		b.retries = 0
+	} else {
+		for i := int64(0); i < n; i++ {
+			b.head = (b.head + 1) % len(b.buckets)
+			b.requests -= b.buckets[b.head].requests
+			b.retries -= b.buckets[b.head].retries
+			b.buckets[b.head] = budgetBucket{}
+		}
+	}
+
+	b.headStart += n * b.slot
+}
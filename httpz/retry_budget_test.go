@@ -0,0 +1,117 @@
+package httpz
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryBudget(t *testing.T) {
+	t.Run("ratio", func(t *testing.T) {
+		b := NewRetryBudget(0.2, 0, time.Minute)
+		for i := 0; i < 10; i++ {
+			b.Deposit()
+		}
+
+		if !b.Withdraw() || !b.Withdraw() {
+			t.Fatal("expected 2 retries to be allowed")
+		}
+		if b.Withdraw() {
+			t.Fatal("expected the third retry to be denied")
+		}
+
+		stats := b.Stats()
+		if stats.Requests != 10 || stats.Retries != 2 || stats.Denied != 1 {
+			t.Errorf("unexpected stats: %+v", stats)
+		}
+	})
+
+	t.Run("min per second", func(t *testing.T) {
+		b := NewRetryBudget(0, 1, 3*time.Second)
+		for i := 0; i < 3; i++ {
+			if !b.Withdraw() {
+				t.Fatalf("expected retry %d to be allowed", i+1)
+			}
+		}
+		if b.Withdraw() {
+			t.Fatal("expected retry to be denied")
+		}
+	})
+
+	t.Run("sliding window", func(t *testing.T) {
+		b := NewRetryBudget(1, 0, 100*time.Millisecond)
+		b.Deposit()
+		if !b.Withdraw() {
+			t.Fatal("expected retry to be allowed")
+		}
+		if b.Withdraw() {
+			t.Fatal("expected retry to be denied")
+		}
+
+		time.Sleep(150 * time.Millisecond)
+		if b.Withdraw() {
+			t.Fatal("expected retry to be denied after requests expired")
+		}
+
+		b.Deposit()
+		if !b.Withdraw() {
+			t.Fatal("expected retry to be allowed after retries expired")
+		}
+	})
+}
+
+func TestClient_RetryBudget(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	budget := NewRetryBudget(0.5, 0, time.Minute)
+	client := NewClient(WithRetryPolicy(RetryPolicy{
+		MaxRetries: 3,
+		Budget:     budget,
+	}))
+
+	for i := 0; i < 4; i++ {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	// retries are limited to 50% of 4 requests
+	if n := atomic.LoadInt32(&attempts); n != 6 {
+		t.Errorf("expected 6 attempts, got %d", n)
+	}
+
+	stats := budget.Stats()
+	if stats.Requests != 4 || stats.Retries != 2 || stats.Denied != 4 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	// a retry which cannot rebuild the body does not spend the budget
+	budget = NewRetryBudget(0.5, 10, time.Minute)
+	client = NewClient(WithRetryPolicy(RetryPolicy{MaxRetries: 3, Budget: budget}))
+	req, _ := http.NewRequest(http.MethodPut, server.URL, strings.NewReader("x"))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return nil, errors.New("cannot rebuild")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if stats = budget.Stats(); stats.Retries != 0 || stats.Denied != 0 {
+		t.Errorf("expected no withdrawal, got %+v", stats)
+	}
+}
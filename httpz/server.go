@@ -0,0 +1,312 @@
+package httpz
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/welllog/golib/goz"
+	"github.com/welllog/golib/ipz"
+	"github.com/welllog/golib/randz"
+)
+
+const (
+	// HeaderRequestID default header of the request id.
+	HeaderRequestID = "X-Request-Id"
+
+	defaultPanicStackDeep = 32
+)
+
+type requestIDKey struct{}
+
+type realIPKey struct{}
+
+// HandlerMiddleware wraps an http.Handler, it is the server side counterpart of Middleware.
+type HandlerMiddleware func(http.Handler) http.Handler
+
+// Chain wraps the handler with the middlewares, the first middleware is the outermost, like WithMiddleware.
+func Chain(h http.Handler, mws ...HandlerMiddleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// RecoverHandler recovers the panics of the handler through goz.Recover and logs them through goz.LogPanic,
+// a 500 response is written if the handler has not written the header.
+// http.ErrAbortHandler is panicked again to abort the response. if l is nil, the standard log package is used.
+func RecoverHandler(l goz.Logger) HandlerMiddleware {
+	if l == nil {
+		l = stdLogger{}
+	}
+	logPanic := goz.LogPanic(l, defaultPanicStackDeep)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sw, rw := wrapResponseWriter(w)
+
+			var panicked bool
+			goz.Recover(func() {
+				next.ServeHTTP(rw, r)
+			}, func(p any) {
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+				panicked = true
+				logPanic(p)
+			})
+
+			if panicked && sw.status == 0 {
+				http.Error(sw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		})
+	}
+}
+
+// RequestIDHandler puts the request id into the request context and the response header.
+// The request id is taken from the request header, or generated by randz.Id if absent.
+// if header is empty, it defaults to X-Request-Id.
+func RequestIDHandler(header string) HandlerMiddleware {
+	if header == "" {
+		header = HeaderRequestID
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(header)
+			if id == "" {
+				id = randz.Id().String()
+			}
+
+			w.Header().Set(header, id)
+			next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
+		})
+	}
+}
+
+// WithRequestID returns a ctx carrying the request id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request id carried by ctx.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// PropagateRequestID returns the client middleware which sets the request id carried by the request context
+// to the request header, so the request id flows to the upstream. if header is empty, it defaults to X-Request-Id.
+func PropagateRequestID(header string) Middleware {
+	if header == "" {
+		header = HeaderRequestID
+	}
+
+	return func(next DoFunc) DoFunc {
+		return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			if id := RequestIDFromContext(ctx); id != "" && req.Header.Get(header) == "" {
+				req.Header.Set(header, id)
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// RealIPHandler puts the client ip resolved by ipz.GetRemoteIp into the request context.
+// The X-Forwarded-For and X-Real-Ip headers are trusted, it should only be used behind trusted proxies.
+func RealIPHandler() HandlerMiddleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), realIPKey{}, ipz.GetRemoteIp(r))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RealIPFromContext returns the client ip carried by ctx.
+func RealIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(realIPKey{}).(string)
+	return ip
+}
+
+// AccessLogHandler logs the method, path, status, size, latency, client ip and request id of each request.
+// Responses with 5xx status are logged through l.Error. if l is nil, the standard log package is used.
+// It should be placed after RequestIDHandler and RealIPHandler, and before RecoverHandler, e.g.
+//
+//	Chain(h, RequestIDHandler(""), RealIPHandler(), AccessLogHandler(l), RecoverHandler(l))
+func AccessLogHandler(l Logger) HandlerMiddleware {
+	if l == nil {
+		l = stdLogger{}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			begin := time.Now()
+			sw, rw := wrapResponseWriter(w)
+
+			defer func() {
+				status := sw.status
+				if status == 0 {
+					status = http.StatusOK
+				}
+
+				ip := RealIPFromContext(r.Context())
+				if ip == "" {
+					ip = ipz.GetRemoteIp(r)
+				}
+
+				var sb strings.Builder
+				sb.Grow(128)
+				sb.WriteString(r.Method)
+				sb.WriteByte(' ')
+				sb.WriteString(r.URL.RequestURI())
+				sb.WriteString(" status=")
+				sb.WriteString(strconv.Itoa(status))
+				sb.WriteString(" size=")
+				sb.WriteString(strconv.FormatInt(sw.size, 10))
+				sb.WriteString(" latency=")
+				sb.WriteString(time.Since(begin).String())
+				sb.WriteString(" ip=")
+				sb.WriteString(ip)
+				if id := RequestIDFromContext(r.Context()); id != "" {
+					sb.WriteString(" request_id=")
+					sb.WriteString(id)
+				}
+
+				if status >= 500 {
+					l.Error(sb.String())
+				} else {
+					l.Info(sb.String())
+				}
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// TimeoutHandler runs the handler with the timeout through http.TimeoutHandler,
+// a 503 response with msg is written if the handler times out.
+func TimeoutHandler(timeout time.Duration, msg string) HandlerMiddleware {
+	return func(next http.Handler) http.Handler {
+		return http.TimeoutHandler(next, timeout, msg)
+	}
+}
+
+// statusWriter records the status and size of the response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+// wrapResponseWriter returns the statusWriter of w and the ResponseWriter to pass down, which implements
+// http.Flusher and http.Hijacker only if w does, so the capability checks of the handlers stay correct.
+func wrapResponseWriter(w http.ResponseWriter) (*statusWriter, http.ResponseWriter) {
+	if rw, ok := w.(interface{ recorder() *statusWriter }); ok {
+		return rw.recorder(), w
+	}
+
+	sw := &statusWriter{ResponseWriter: w}
+	_, flusher := w.(http.Flusher)
+	_, hijacker := w.(http.Hijacker)
+	switch {
+	case flusher && hijacker:
+		return sw, flushHijackWriter{sw}
+	case flusher:
+		return sw, flushWriter{sw}
+	case hijacker:
+		return sw, hijackWriter{sw}
+	}
+	return sw, sw
+}
+
+func (w *statusWriter) recorder() *statusWriter {
+	return w
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// ReadFrom copies r to the response through the io.ReaderFrom of the wrapped ResponseWriter, e.g. sendfile.
+func (w *statusWriter) ReadFrom(r io.Reader) (int64, error) {
+	rf, ok := w.ResponseWriter.(io.ReaderFrom)
+	if !ok {
+		// hide ReadFrom from io.Copy, the writes are recorded by Write
+		return io.Copy(struct{ io.Writer }{w}, r)
+	}
+
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := rf.ReadFrom(r)
+	w.size += n
+	return n, err
+}
+
+func (w *statusWriter) flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w *statusWriter) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Unwrap returns the wrapped ResponseWriter for http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+type flushWriter struct {
+	*statusWriter
+}
+
+func (w flushWriter) Flush() {
+	w.flush()
+}
+
+// hijackWriter supports the connection hijacking, e.g. for WebSocket upgrades.
+type hijackWriter struct {
+	*statusWriter
+}
+
+func (w hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.hijack()
+}
+
+type flushHijackWriter struct {
+	*statusWriter
+}
+
+func (w flushHijackWriter) Flush() {
+	w.flush()
+}
+
+func (w flushHijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.hijack()
+}
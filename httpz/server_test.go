@@ -0,0 +1,192 @@
+package httpz
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandlerChain(t *testing.T) {
+	logger := &recordLogger{}
+	var order []string
+	mark := func(name string) HandlerMiddleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/panic":
+			panic("boom")
+		case "/info":
+			_, _ = fmt.Fprintf(w, "%s %s", RequestIDFromContext(r.Context()), RealIPFromContext(r.Context()))
+		}
+	}), mark("a"), RequestIDHandler(""), RealIPHandler(), AccessLogHandler(logger), RecoverHandler(logger), mark("b"))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/info?x=1", nil)
+	r.Header.Set("X-Forwarded-For", "1.2.3.4, 10.0.0.1")
+	h.ServeHTTP(w, r)
+
+	id := w.Header().Get(HeaderRequestID)
+	if id == "" || w.Body.String() != id+" 1.2.3.4" {
+		t.Errorf("unexpected response: %s, request id %s", w.Body.String(), id)
+	}
+	if strings.Join(order, ",") != "a,b" {
+		t.Errorf("unexpected order: %v", order)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/panic", nil)
+	r.Header.Set(HeaderRequestID, "req-1")
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusInternalServerError || w.Header().Get(HeaderRequestID) != "req-1" {
+		t.Errorf("unexpected panic response: %d %v", w.Code, w.Header())
+	}
+
+	infos, errs := logger.lines()
+	if len(infos) != 1 || !strings.Contains(infos[0], "GET /info?x=1 status=200 size=") ||
+		!strings.Contains(infos[0], "ip=1.2.3.4 request_id="+id) {
+		t.Errorf("unexpected access logs: %v", infos)
+	}
+	if len(errs) != 2 || !strings.Contains(errs[0], "panic: boom") ||
+		!strings.Contains(errs[1], "GET /panic status=500") || !strings.Contains(errs[1], "request_id=req-1") {
+		t.Errorf("unexpected error logs: %v", errs)
+	}
+}
+
+func TestHandlerChain_Hijack(t *testing.T) {
+	logger := &recordLogger{}
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/file" {
+			_, _ = io.Copy(w, strings.NewReader("file content"))
+			return
+		}
+
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			t.Error("expected the wrapped writer to implement http.Hijacker")
+			return
+		}
+		conn, rw, err := hj.Hijack()
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+		defer conn.Close()
+		_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: test\r\nConnection: Upgrade\r\n\r\nhello")
+		_ = rw.Flush()
+	}), AccessLogHandler(logger), RecoverHandler(logger))
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_, _ = conn.Write([]byte("GET /ws HTTP/1.1\r\nHost: test\r\nUpgrade: test\r\nConnection: Upgrade\r\n\r\n"))
+
+	b, _ := io.ReadAll(conn)
+	if !strings.HasPrefix(string(b), "HTTP/1.1 101") || !strings.HasSuffix(string(b), "hello") {
+		t.Errorf("unexpected hijacked response: %q", b)
+	}
+
+	resp, err := http.Get(server.URL + "/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(b) != "file content" {
+		t.Errorf("unexpected response: %q", b)
+	}
+
+	infos, errs := logger.lines()
+	logs := strings.Join(infos, "\n")
+	if len(infos) != 2 || !strings.Contains(logs, "GET /ws status=101") ||
+		!strings.Contains(logs, "GET /file status=200 size=12") || len(errs) != 0 {
+		t.Errorf("unexpected logs: %v %v", infos, errs)
+	}
+
+}
+
+func TestWrapResponseWriter(t *testing.T) {
+	_, w := wrapResponseWriter(httptest.NewRecorder())
+	if _, ok := w.(http.Hijacker); ok {
+		t.Error("expected the wrapper of a non-Hijacker not to implement http.Hijacker")
+	}
+	if _, ok := w.(http.Flusher); !ok {
+		t.Error("expected the wrapper of a Flusher to implement http.Flusher")
+	}
+
+	_, w = wrapResponseWriter(struct{ http.ResponseWriter }{httptest.NewRecorder()})
+	if _, ok := w.(http.Flusher); ok {
+		t.Error("expected the wrapper of a non-Flusher not to implement http.Flusher")
+	}
+
+	// the nested wrappers record the same status
+	sw, w := wrapResponseWriter(httptest.NewRecorder())
+	inner, _ := wrapResponseWriter(w)
+	w.WriteHeader(http.StatusTeapot)
+	if inner != sw || sw.status != http.StatusTeapot {
+		t.Errorf("expected the nested wrapper to be reused, got status %d", sw.status)
+	}
+	w.(http.Flusher).Flush()
+}
+
+func TestRecoverHandler_Abort(t *testing.T) {
+	h := RecoverHandler(&recordLogger{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Errorf("expected http.ErrAbortHandler, got %v", p)
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestTimeoutHandler(t *testing.T) {
+	h := TimeoutHandler(20*time.Millisecond, "timeout")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Body.String() != "timeout" {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestPropagateRequestID(t *testing.T) {
+	server := httptest.NewServer(RequestIDHandler("")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(RequestIDFromContext(r.Context())))
+	})))
+	defer server.Close()
+
+	client := NewClient(WithMiddleware(PropagateRequestID("")))
+	req, _ := http.NewRequestWithContext(WithRequestID(context.Background(), "req-2"), http.MethodGet, server.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	b, _ := io.ReadAll(resp.Body)
+	if string(b) != "req-2" {
+		t.Errorf("expected propagated request id, got %s", b)
+	}
+}
@@ -0,0 +1,294 @@
+package httpz
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultSSERetry       = 3 * time.Second
+	defaultSSEMaxLineSize = 1 << 20
+	mimeEventStream       = "text/event-stream"
+)
+
+// ErrEventSourceClosed is returned by EventSource.Next after Close.
+var ErrEventSourceClosed = errors.New("httpz: event source closed")
+
+// Event is a Server-Sent Event.
+type Event struct {
+	// ID the last event id when the event was dispatched
+	ID string
+	// Event the event type, default is "message"
+	Event string
+	// Data the event data, multiple data lines are joined by "\n"
+	Data string
+}
+
+// SSEConfig configures an EventSource.
+type SSEConfig struct {
+	// Retry initial reconnection delay, it is replaced by the retry field sent by the server. Default is 3s
+	Retry time.Duration
+	// MaxReconnects maximum number of consecutive reconnections, the count is reset once a connection succeeds.
+	// Default is 0, means no limit. Less than 0 disables reconnection
+	MaxReconnects int
+	// LastEventID initial Last-Event-ID header of the first connection
+	LastEventID string
+	// MaxLineSize maximum size of a line of the stream. Default is 1MB
+	MaxLineSize int
+}
+
+// EventSource reads Server-Sent Events of a request, and reconnects with the Last-Event-ID header
+// when the stream ends or the connection is broken.
+// The EventSource stops when the request context is done, a non-200 response is returned,
+// or the server responds with 204 No Content.
+type EventSource struct {
+	client     *Client
+	req        *http.Request
+	cfg        SSEConfig
+	retry      time.Duration
+	lastID     string
+	reconnects int
+	body       io.ReadCloser
+	scanner    *bufio.Scanner
+	err        error
+}
+
+// NewEventSource creates a new EventSource, the connection is established on the first Next call.
+// The request is sent through the client each time the EventSource connects, its body is rebuilt through
+// req.GetBody.
+func NewEventSource(c *Client, req *http.Request, cfg SSEConfig) *EventSource {
+	if cfg.Retry <= 0 {
+		cfg.Retry = defaultSSERetry
+	}
+	if cfg.MaxLineSize <= 0 {
+		cfg.MaxLineSize = defaultSSEMaxLineSize
+	}
+
+	return &EventSource{
+		client: c,
+		req:    req,
+		cfg:    cfg,
+		retry:  cfg.Retry,
+		lastID: cfg.LastEventID,
+	}
+}
+
+// LastEventID returns the last event id received.
+func (s *EventSource) LastEventID() string {
+	return s.lastID
+}
+
+// Next returns the next event.
+// It returns io.EOF if the stream ends without reconnection, and the context error if the request context is done.
+func (s *EventSource) Next() (Event, error) {
+	ctx := s.req.Context()
+	for {
+		if s.err != nil {
+			return Event{}, s.err
+		}
+
+		if s.body == nil {
+			reconnect, err := s.connect()
+			if err != nil {
+				if !reconnect || !s.wait() {
+					s.err = err
+				}
+				continue
+			}
+		}
+
+		ev, err := s.read()
+		if err == nil {
+			return ev, nil
+		}
+
+		s.closeBody()
+		if ctx.Err() != nil {
+			s.err = ctx.Err()
+			continue
+		}
+
+		// the line is too long to be read by any reconnection
+		if errors.Is(err, bufio.ErrTooLong) {
+			s.err = err
+			continue
+		}
+
+		if !s.wait() {
+			s.err = err
+		}
+	}
+}
+
+// Close closes the current connection, Next returns ErrEventSourceClosed after it.
+// Close must not be called concurrently with Next, cancel the request context to stop a blocked Next instead.
+func (s *EventSource) Close() error {
+	s.closeBody()
+	s.err = ErrEventSourceClosed
+	return nil
+}
+
+// connect sends the request, and reports whether a reconnection should be made if it fails.
+func (s *EventSource) connect() (bool, error) {
+	ctx := s.req.Context()
+	req, err := cloneRequest(ctx, s.req)
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Accept", mimeEventStream)
+	req.Header.Set("Cache-Control", "no-cache")
+	if s.lastID != "" {
+		req.Header.Set("Last-Event-ID", s.lastID)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+
+	if resp.StatusCode == http.StatusNoContent {
+		_ = resp.Body.Close()
+		return false, io.EOF
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return false, newHTTPError(resp, nil)
+	}
+
+	if mediaType := parseMediaType(resp.Header.Get("Content-Type")); mediaType != mimeEventStream {
+		_ = resp.Body.Close()
+		return false, fmt.Errorf("httpz: unexpected event stream content type %q", mediaType)
+	}
+
+	s.reconnects = 0
+	s.body = resp.Body
+	s.scanner = bufio.NewScanner(resp.Body)
+	// the capacity of the initial buffer also bounds the line size, it must not exceed MaxLineSize
+	size := 4096
+	if size > s.cfg.MaxLineSize {
+		size = s.cfg.MaxLineSize
+	}
+	s.scanner.Buffer(make([]byte, 0, size), s.cfg.MaxLineSize)
+	s.scanner.Split(scanEventLines)
+	return false, nil
+}
+
+// wait waits the retry delay before reconnecting, and reports whether the reconnection can be made.
+func (s *EventSource) wait() bool {
+	if s.cfg.MaxReconnects < 0 || (s.cfg.MaxReconnects > 0 && s.reconnects >= s.cfg.MaxReconnects) {
+		return false
+	}
+
+	s.reconnects++
+	if err := sleepContext(s.req.Context(), s.retry); err != nil {
+		s.err = err
+	}
+	return true
+}
+
+// read parses the stream until an event is dispatched.
+func (s *EventSource) read() (Event, error) {
+	var ev Event
+	var data strings.Builder
+	var hasData bool
+
+	for s.scanner.Scan() {
+		line := s.scanner.Bytes()
+		if len(line) == 0 {
+			if !hasData {
+				ev.Event = ""
+				continue
+			}
+
+			ev.ID = s.lastID
+			ev.Data = data.String()
+			if ev.Event == "" {
+				ev.Event = "message"
+			}
+			return ev, nil
+		}
+
+		if line[0] == ':' {
+			continue
+		}
+
+		field, value := line, []byte(nil)
+		if i := bytes.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], line[i+1:]
+			if len(value) > 0 && value[0] == ' ' {
+				value = value[1:]
+			}
+		}
+
+		switch string(field) {
+		case "event":
+			ev.Event = string(value)
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.Write(value)
+			hasData = true
+		case "id":
+			if bytes.IndexByte(value, 0) < 0 {
+				s.lastID = string(value)
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(string(value), 10, 63); err == nil {
+				s.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+
+	if err := s.scanner.Err(); err != nil {
+		return Event{}, err
+	}
+	return Event{}, io.EOF
+}
+
+func (s *EventSource) closeBody() {
+	if s.body != nil {
+		_ = s.body.Close()
+		s.body = nil
+		s.scanner = nil
+	}
+}
+
+// scanEventLines splits the stream into lines ending with "\r\n", "\n" or "\r".
+func scanEventLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+
+		if i+1 < len(data) {
+			if data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+			return i + 1, data[:i], nil
+		}
+
+		if atEOF {
+			return i + 1, data[:i], nil
+		}
+		// need more data to know whether "\n" follows
+		return 0, nil, nil
+	}
+
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
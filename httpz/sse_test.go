@@ -0,0 +1,155 @@
+package httpz
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEventSource(t *testing.T) {
+	var conns int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		switch atomic.AddInt32(&conns, 1) {
+		case 1:
+			if r.Header.Get("Accept") != "text/event-stream" || r.Header.Get("Last-Event-ID") != "" {
+				t.Errorf("unexpected headers: %v", r.Header)
+			}
+			_, _ = w.Write([]byte(": comment\nretry: 10\n\ndata: hello\ndata:  world\r\n\r\n" +
+				"event: update\nid: 1\ndata\n\nid: 2\ndata: partial"))
+		case 2:
+			if r.Header.Get("Last-Event-ID") != "2" {
+				t.Errorf("unexpected Last-Event-ID: %s", r.Header.Get("Last-Event-ID"))
+			}
+			_, _ = w.Write([]byte("id\rdata: after\r\r"))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	es := NewEventSource(NewClient(), req, SSEConfig{})
+
+	want := []Event{
+		{Event: "message", Data: "hello\n world"},
+		{ID: "1", Event: "update", Data: ""},
+		{ID: "", Event: "message", Data: "after"},
+	}
+
+	start := time.Now()
+	for i, w := range want {
+		ev, err := es.Next()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ev != w {
+			t.Errorf("event %d: expected %+v, got %+v", i, w, ev)
+		}
+	}
+
+	if _, err := es.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("expected the retry field to be respected")
+	}
+	if atomic.LoadInt32(&conns) != 3 {
+		t.Errorf("expected 3 connections, got %d", conns)
+	}
+}
+
+func TestEventSource_Stop(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/error":
+			w.WriteHeader(http.StatusUnauthorized)
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+		default:
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte("data: 1\n\n"))
+		}
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/error", nil)
+	_, err := NewEventSource(NewClient(), req, SSEConfig{}).Next()
+	if !errors.Is(err, ErrClientStatus) {
+		t.Errorf("expected client status error, got %v", err)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/json", nil)
+	if _, err = NewEventSource(NewClient(), req, SSEConfig{}).Next(); err == nil {
+		t.Error("expected content type error")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	es := NewEventSource(NewClient(), req, SSEConfig{})
+	if _, err = es.Next(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err = es.Next(); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	_ = es.Close()
+	if _, err = es.Next(); !errors.Is(err, ErrEventSourceClosed) {
+		t.Errorf("expected ErrEventSourceClosed, got %v", err)
+	}
+}
+
+func TestEventSource_Reconnect(t *testing.T) {
+	var conns int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&conns, 1)
+		switch {
+		case r.URL.Path == "/long":
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte("data: " + strings.Repeat("a", 64) + "\n\n"))
+		case n >= 7:
+			w.WriteHeader(http.StatusNoContent)
+		case n%2 == 0:
+			// break the connection without a response
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			_ = conn.Close()
+		default:
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte("data: 1\n\n"))
+		}
+	}))
+	defer server.Close()
+
+	// every other connection fails, the successful connections reset the count.
+	// no keep-alive, so the transport does not retry the broken connections itself
+	client := NewClient(WithHttpClient(&http.Client{Transport: &http.Transport{DisableKeepAlives: true}}))
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	es := NewEventSource(client, req, SSEConfig{MaxReconnects: 2, Retry: time.Millisecond})
+	var n int
+	var err error
+	for {
+		if _, err = es.Next(); err != nil {
+			break
+		}
+		n++
+	}
+	if n != 3 || !errors.Is(err, io.EOF) {
+		t.Errorf("expected 3 events and io.EOF, got %d %v", n, err)
+	}
+
+	atomic.StoreInt32(&conns, 0)
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/long", nil)
+	es = NewEventSource(NewClient(), req, SSEConfig{Retry: time.Millisecond, MaxLineSize: 16})
+	if _, err = es.Next(); !errors.Is(err, bufio.ErrTooLong) || atomic.LoadInt32(&conns) != 1 {
+		t.Errorf("expected bufio.ErrTooLong without reconnection, got %v after %d connections", err, conns)
+	}
+}
@@ -0,0 +1,80 @@
+//go:build go1.23
+
+package httpz
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"net/http"
+)
+
+// All returns an iterator over the events, the EventSource is closed when the iteration stops.
+// The iteration stops after yielding an error, the end of the stream is not yielded as an error.
+func (s *EventSource) All() iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		defer s.Close()
+
+		for {
+			ev, err := s.Next()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					yield(ev, err)
+				}
+				return
+			}
+
+			if !yield(ev, nil) {
+				return
+			}
+		}
+	}
+}
+
+// DecodeNDJSON returns an iterator over the newline-delimited JSON values of r.
+// The iteration stops after yielding an error, including the context error once the ctx is done.
+func DecodeNDJSON[T any](ctx context.Context, r io.Reader) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		d := NewNDJSONDecoder[T](ctx, r)
+		for {
+			v, err := d.Decode()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					yield(v, err)
+				}
+				return
+			}
+
+			if !yield(v, nil) {
+				return
+			}
+		}
+	}
+}
+
+// StreamNDJSON sends the request through the client with the ctx, and returns an iterator over the
+// newline-delimited JSON values of the response. Non-2xx responses are yielded as *HTTPError.
+// The response body is closed when the iteration stops.
+func StreamNDJSON[T any](ctx context.Context, c *Client, req *http.Request) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		resp, err := c.Do(req.WithContext(ctx))
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			yield(zero, newHTTPError(resp, nil))
+			return
+		}
+
+		for v, err := range DecodeNDJSON[T](ctx, resp.Body) {
+			if !yield(v, err) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+//go:build go1.23
+
+package httpz
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventSource_All(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: a\n\ndata: b\n\n"))
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	var data []string
+	for ev, err := range NewEventSource(NewClient(), req, SSEConfig{MaxReconnects: -1}).All() {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		data = append(data, ev.Data)
+	}
+
+	if strings.Join(data, ",") != "a,b" {
+		t.Errorf("unexpected events: %v", data)
+	}
+}
+
+func TestDecodeNDJSON(t *testing.T) {
+	var ids []int
+	for u, err := range DecodeNDJSON[typedUser](context.Background(), strings.NewReader("{\"id\":1}\n{\"id\":2}\n{\"id\":3}")) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ids = append(ids, u.ID)
+		if u.ID == 2 {
+			break
+		}
+	}
+
+	if len(ids) != 2 {
+		t.Errorf("unexpected ids: %v", ids)
+	}
+}
+
+func TestStreamNDJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for i := 1; i <= 2; i++ {
+			_, _ = w.Write([]byte(`{"id":1}` + "\n"))
+			w.(http.Flusher).Flush()
+		}
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	var n int
+	var lastErr error
+	for _, err := range StreamNDJSON[typedUser](ctx, NewClient(), req) {
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		n++
+		if n == 2 {
+			time.AfterFunc(20*time.Millisecond, cancel)
+		}
+	}
+
+	if n != 2 || !errors.Is(lastErr, context.Canceled) {
+		t.Errorf("expected 2 values and context.Canceled, got %d %v", n, lastErr)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/missing", nil)
+	for _, err := range StreamNDJSON[typedUser](context.Background(), NewClient(), req) {
+		if !errors.Is(err, ErrClientStatus) {
+			t.Errorf("expected client status error, got %v", err)
+		}
+	}
+}
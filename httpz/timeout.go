@@ -0,0 +1,90 @@
+package httpz
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrAttemptTimeout reports that a single attempt exceeded RetryPolicy.AttemptTimeout.
+	ErrAttemptTimeout = errors.New("httpz: attempt timed out")
+	// ErrTotalTimeout reports that the deadline budget of all attempts ran out.
+	ErrTotalTimeout = errors.New("httpz: deadline budget ran out")
+)
+
+// TimeoutError is returned when an attempt timed out or the deadline budget of all attempts ran out.
+// Use errors.Is with ErrAttemptTimeout or ErrTotalTimeout to tell them apart.
+type TimeoutError struct {
+	// Attempt the attempt that timed out, starting at 1
+	Attempt int
+	// Total reports whether the deadline budget of all attempts ran out
+	Total bool
+	// Err the underlying error
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	msg := ErrAttemptTimeout.Error()
+	if e.Total {
+		msg = ErrTotalTimeout.Error()
+	}
+
+	msg += " at attempt " + strconv.Itoa(e.Attempt)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+func (e *TimeoutError) Is(target error) bool {
+	if e.Total {
+		return target == ErrTotalTimeout
+	}
+	return target == ErrAttemptTimeout
+}
+
+// Timeout implements the net.Error timeout interface.
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+// budgetCovers reports whether the remaining time before ctx deadline is longer than d.
+func budgetCovers(ctx context.Context, d time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return true
+	}
+
+	return time.Until(deadline) > d
+}
+
+// cancelBody cancels the request context once the response body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// bindCancel defers cancel until the response body is closed, so the body can still be read by the caller.
+func bindCancel(resp *http.Response, err error, cancel context.CancelFunc) (*http.Response, error) {
+	if err != nil || resp == nil || resp.Body == nil {
+		cancel()
+		return resp, err
+	}
+
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
@@ -0,0 +1,168 @@
+package httpz
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_AttemptTimeout(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		_, _ = w.Write([]byte("success"))
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := client.DoWithRetry(req, RetryPolicy{
+		MaxRetries:     3,
+		MinRetryDelay:  time.Millisecond,
+		AttemptTimeout: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		t.Fatalf("unexpected read error: %v", err)
+	}
+	if string(body) != "success" {
+		t.Errorf("expected body 'success', got '%s'", string(body))
+	}
+	if n := atomic.LoadInt32(&attempts); n != 3 {
+		t.Errorf("expected 3 attempts, got %d", n)
+	}
+}
+
+func TestClient_AttemptTimeoutError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	_, err := client.DoWithRetry(req, RetryPolicy{
+		MaxRetries:     1,
+		AttemptTimeout: 20 * time.Millisecond,
+	})
+
+	var te *TimeoutError
+	if !errors.As(err, &te) {
+		t.Fatalf("expected TimeoutError, got %v", err)
+	}
+	if te.Attempt != 2 || te.Total {
+		t.Errorf("expected attempt timeout at attempt 2, got %+v", te)
+	}
+	if !errors.Is(err, ErrAttemptTimeout) || errors.Is(err, ErrTotalTimeout) {
+		t.Errorf("expected ErrAttemptTimeout only, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected error to wrap context deadline exceeded, got %v", err)
+	}
+}
+
+func TestClient_TotalTimeout(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	begin := time.Now()
+	_, err := client.DoWithRetry(req, RetryPolicy{
+		MaxRetries:     5,
+		AttemptTimeout: 40 * time.Millisecond,
+		TotalTimeout:   100 * time.Millisecond,
+	})
+
+	if time.Since(begin) > 500*time.Millisecond {
+		t.Errorf("expected to stop within the budget, took %v", time.Since(begin))
+	}
+	if !errors.Is(err, ErrTotalTimeout) {
+		t.Errorf("expected ErrTotalTimeout, got %v", err)
+	}
+	if n := atomic.LoadInt32(&attempts); n != 2 {
+		t.Errorf("expected 2 attempts, got %d", n)
+	}
+}
+
+func TestClient_TotalTimeoutSkipAttempt(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	begin := time.Now()
+	resp, err := client.DoWithRetry(req, RetryPolicy{
+		MaxRetries:    3,
+		MinRetryDelay: time.Second,
+		TotalTimeout:  200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", resp.StatusCode)
+	}
+	if time.Since(begin) > 100*time.Millisecond {
+		t.Errorf("expected no wait when budget cannot cover the delay, took %v", time.Since(begin))
+	}
+	if n := atomic.LoadInt32(&attempts); n != 1 {
+		t.Errorf("expected 1 attempt, got %d", n)
+	}
+}
+
+func TestClient_TotalTimeoutReadBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("success"))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithRetryPolicy(RetryPolicy{
+		AttemptTimeout: time.Second,
+		TotalTimeout:   time.Second,
+	}))
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil || string(body) != "success" {
+		t.Errorf("expected body 'success', got '%s' %v", string(body), err)
+	}
+}
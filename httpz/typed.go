@@ -0,0 +1,107 @@
+package httpz
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/welllog/golib/strz"
+)
+
+// Get sends a GET request and decodes the 2xx response into T.
+// Other status codes are returned as *HTTPError.
+func Get[T any](ctx context.Context, c *Client, url string, headers map[string]string, codec Codec) (T, error) {
+	return Send[T](ctx, c, http.MethodGet, url, headers, nil, codec)
+}
+
+// Post sends a POST request and decodes the 2xx response into T.
+// body supports string, []byte, *Multipart, io.Reader, and other types serialized through codec
+func Post[T any](ctx context.Context, c *Client, url string, headers map[string]string, body any,
+	codec Codec) (T, error) {
+	return Send[T](ctx, c, http.MethodPost, url, headers, body, codec)
+}
+
+// Put sends a PUT request and decodes the 2xx response into T.
+func Put[T any](ctx context.Context, c *Client, url string, headers map[string]string, body any,
+	codec Codec) (T, error) {
+	return Send[T](ctx, c, http.MethodPut, url, headers, body, codec)
+}
+
+// Patch sends a PATCH request and decodes the 2xx response into T.
+func Patch[T any](ctx context.Context, c *Client, url string, headers map[string]string, body any,
+	codec Codec) (T, error) {
+	return Send[T](ctx, c, http.MethodPatch, url, headers, body, codec)
+}
+
+// Delete sends a DELETE request and decodes the 2xx response into T.
+func Delete[T any](ctx context.Context, c *Client, url string, headers map[string]string, codec Codec) (T, error) {
+	return Send[T](ctx, c, http.MethodDelete, url, headers, nil, codec)
+}
+
+// Send sends a request and decodes the 2xx response into T.
+// body supports string, []byte, *Multipart, io.Reader, and other types serialized through codec
+func Send[T any](ctx context.Context, c *Client, method, url string, headers map[string]string, body any,
+	codec Codec) (T, error) {
+
+	req, err := newRequest(ctx, method, url, headers, body, codec)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return Do[T](c, req, codec)
+}
+
+// Do sends the request and decodes the 2xx response into T.
+// T of []byte and string receives the raw body, other types are decoded through codec.
+// An empty body leaves T as the zero value. Other status codes are returned as *HTTPError,
+// whose body can be decoded by ErrorAs.
+func Do[T any](c *Client, req *http.Request, codec Codec) (T, error) {
+	var out T
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return out, fmt.Errorf("send http request failed: %w", err)
+	}
+
+	err = decodeResponse(resp, &out, codec)
+	return out, err
+}
+
+// decodeResponse decodes the 2xx response into out and closes the body.
+func decodeResponse(resp *http.Response, out any, codec Codec) error {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newHTTPError(resp, codec)
+	}
+
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read http response body failed: %w", err)
+	}
+
+	switch v := out.(type) {
+	case *[]byte:
+		*v = b
+		return nil
+	case *string:
+		*v = string(b)
+		return nil
+	}
+
+	if len(b) == 0 {
+		return nil
+	}
+
+	if codec == nil {
+		return fmt.Errorf("unmarshal http response body failed: no codec for %T", out)
+	}
+
+	if err = unmarshal(codec, resp.Header.Get("Content-Type"), b, out); err != nil {
+		return fmt.Errorf("unmarshal http response body failed: %w, body: %s", err, strz.UnsafeString(b))
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package httpz
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type typedUser struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type typedError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func TestGet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/user":
+			_, _ = w.Write([]byte(`{"id":1,"name":"bob"}`))
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"code":"not_found","message":"no such user"}`))
+		case "/broken":
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(`upstream down`))
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	ctx := context.Background()
+
+	user, err := Get[typedUser](ctx, client, server.URL+"/user", nil, jsonCodec{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if user.ID != 1 || user.Name != "bob" {
+		t.Errorf("unexpected user: %+v", user)
+	}
+
+	_, err = Get[typedUser](ctx, client, server.URL+"/missing", nil, jsonCodec{})
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("expected HTTPError, got %v", err)
+	}
+	if httpErr.StatusCode != http.StatusNotFound || !httpErr.IsClientError() || httpErr.IsServerError() {
+		t.Errorf("unexpected HTTPError: %+v", httpErr)
+	}
+	if httpErr.Method != http.MethodGet || httpErr.URL != server.URL+"/missing" {
+		t.Errorf("unexpected request info: %s %s", httpErr.Method, httpErr.URL)
+	}
+	if !errors.Is(err, ErrClientStatus) || errors.Is(err, ErrServerStatus) {
+		t.Error("expected error to match ErrClientStatus only")
+	}
+
+	payload, ok := ErrorAs[typedError](err)
+	if !ok || payload.Code != "not_found" {
+		t.Errorf("expected decoded error payload, got %+v %v", payload, ok)
+	}
+
+	_, err = Get[typedUser](ctx, client, server.URL+"/broken", nil, jsonCodec{})
+	if !errors.Is(err, ErrServerStatus) {
+		t.Errorf("expected ErrServerStatus, got %v", err)
+	}
+	if _, ok := ErrorAs[typedError](err); ok {
+		t.Error("expected invalid error payload not to be decoded")
+	}
+
+	empty, err := Get[*typedUser](ctx, client, server.URL+"/empty", nil, jsonCodec{})
+	if err != nil || empty != nil {
+		t.Errorf("expected zero value on empty body, got %v %v", empty, err)
+	}
+
+	raw, err := Get[string](ctx, client, server.URL+"/user", nil, nil)
+	if err != nil || raw != `{"id":1,"name":"bob"}` {
+		t.Errorf("expected raw body, got '%s' %v", raw, err)
+	}
+}
+
+func TestPost(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("expected POST, got %s", r.Method)
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	user, err := Post[typedUser](context.Background(), NewClient(), server.URL, nil,
+		typedUser{ID: 2, Name: "alice"}, jsonCodec{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if user.ID != 2 || user.Name != "alice" {
+		t.Errorf("unexpected user: %+v", user)
+	}
+}
+
+func TestDo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Method))
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodPut, server.URL, nil)
+	b, err := Do[[]byte](NewClient(), req, nil)
+	if err != nil || string(b) != http.MethodPut {
+		t.Errorf("expected raw body 'PUT', got '%s' %v", string(b), err)
+	}
+
+	_, err = Get[typedUser](context.Background(), NewClient(), server.URL, nil, jsonCodec{})
+	if err == nil {
+		t.Error("expected unmarshal error")
+	}
+}